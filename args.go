@@ -4,17 +4,24 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"slices"
+	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/sirupsen/logrus"
 )
 
 const (
-	separatorHeader      = ":"
-	separatorDataString  = "="
-	separatorDataRawJSON = ":="
-	separatorQueryParam  = "=="
+	separatorHeader          = ":"
+	separatorHeaderEmpty     = ";"
+	separatorHeaderFile      = ":@"
+	separatorDataString      = "="
+	separatorDataStringFile  = "=@"
+	separatorDataRawJSON     = ":="
+	separatorDataRawJSONFile = ":=@"
+	separatorQueryParam      = "=="
+	separatorQueryParamFile  = "==@"
+	separatorFileUpload      = "@"
 )
 
 var (
@@ -23,80 +30,221 @@ var (
 	// example:
 	// ":=" detected before ":"
 	separators = []string{
+		separatorDataRawJSONFile,
+		separatorQueryParamFile,
 		separatorDataRawJSON,
 		separatorQueryParam,
+		separatorHeaderFile,
+		separatorDataStringFile,
 		separatorHeader,
+		separatorHeaderEmpty,
 		separatorDataString,
+		separatorFileUpload,
 	}
 
-	methods = []string{
-		http.MethodGet,
-		http.MethodHead,
-		http.MethodPost,
-		http.MethodPut,
-		http.MethodPatch,
-		http.MethodDelete,
-	}
+	// separatorChars are the characters that can be escaped with a
+	// backslash to be used literally in a request item, including the
+	// backslash itself.
+	separatorChars = ":=@;\\"
 )
 
-func findSeparator(s string) string {
-	found := ""
-	startAt := len(s)
-	for _, v := range separators {
-		idx := strings.Index(s, v)
-		if idx >= 0 && idx < startAt {
-			found = v
-			startAt = idx
+// isToken reports whether s is a token as defined by RFC 7230, section 3.2.6.
+func isToken(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case strings.ContainsRune("!#$%&'*+-.^_`|~", c):
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// looksLikeMethod reports whether the first argument is a method rather
+// than the URL: like HTTPie, any uppercase token is taken as a method.
+func looksLikeMethod(s string) bool {
+	return isToken(s) && s == strings.ToUpper(s) && strings.ToLower(s) != s
+}
+
+// splitItem splits a request item at its first unescaped separator. A
+// backslash followed by a separator character or a backslash escapes it,
+// and is removed from the returned key and value.
+func splitItem(s string) (key, sep, value string) {
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && strings.IndexByte(separatorChars, s[i+1]) >= 0 {
+			i++
+			continue
+		}
+		for _, v := range separators {
+			if strings.HasPrefix(s[i:], v) {
+				return unescapeItem(s[:i]), v, unescapeItem(s[i+len(v):])
+			}
+		}
+	}
+
+	return unescapeItem(s), "", ""
+}
+
+func unescapeItem(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && strings.IndexByte(separatorChars, s[i+1]) >= 0 {
+			i++
 		}
+		b.WriteByte(s[i])
 	}
 
-	return found
+	return b.String()
+}
+
+// readItemFile returns the content of the file referenced by a request item.
+func readItemFile(path string) (string, error) {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		path = filepath.Join(home, rest)
+	}
+
+	b, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return "", fmt.Errorf("cannot read '%s': %w", path, err)
+	}
+
+	return string(b), nil
 }
 
 // ParseArgs parses args and update options.
 func ParseArgs(args []string, opts *Options) error {
-	if !slices.Contains(methods, args[0]) {
+	return parseArgs(args, opts, true)
+}
+
+// ParseLiteralArgs parses args like ParseArgs, without interpolating
+// "${VAR}" or "{{ ... }}", for args whose values were already substituted.
+func ParseLiteralArgs(args []string, opts *Options) error {
+	return parseArgs(args, opts, false)
+}
+
+func parseArgs(args []string, opts *Options, interpolated bool) error {
+	if opts.explicitMethod || len(args) == 1 || !looksLikeMethod(args[0]) {
 		args = append([]string{opts.Method}, args...)
 	}
+	if !isToken(args[0]) {
+		return fmt.Errorf("'%s' is not a valid HTTP method", args[0])
+	}
 	opts.Method = args[0]
-	opts.URL = args[1]
+	if !interpolated {
+		ParseTarget(args[1], opts)
+		return parseItems(args[2:], opts, false)
+	}
+	u, err := interpolate(args[1], escapeURL)
+	if err != nil {
+		return err
+	}
+	ParseTarget(u, opts)
+
+	return ParseItems(args[2:], opts)
+}
+
+// ParseTarget sets the URL of opts to target. Unlike ParseArgs, target is
+// taken literally, without interpolating "${VAR}" or "{{ ... }}", so that
+// it can come from an untrusted source, like a probe of httpcheck serve.
+func ParseTarget(target string, opts *Options) {
+	opts.URL = target
+	if opts.UnixSocket != "" && strings.HasPrefix(opts.URL, "/") {
+		// a bare path is enough when the host only matters for the Host header.
+		opts.URL = "localhost" + opts.URL
+	}
 	if !strings.HasPrefix(opts.URL, "http://") && !strings.HasPrefix(opts.URL, "https://") {
 		opts.URL = "http://" + opts.URL
 	}
+}
 
-	for _, arg := range args[2:] {
-		sep := findSeparator(arg)
-		tokens := strings.SplitN(arg, sep, 2)
-		if len(tokens) == 1 {
-			tokens = append(tokens, "")
-		}
-		logrus.Debugf("separator: '%s', toekns: %v", sep, tokens)
-		k, v := tokens[0], tokens[1]
-
-		switch sep {
-		case separatorHeader:
-			opts.Header.Add(k, v)
-		case separatorDataString:
-			if opts.IsForm {
-				opts.FormData.Add(k, v)
-			} else {
-				opts.Data[k] = v
-			}
-		case separatorDataRawJSON:
-			if opts.IsForm {
-				return fmt.Errorf("cannot use json value type '%s' with --form", arg)
-			}
+// ParseItems parses the request items args and update options.
+func ParseItems(args []string, opts *Options) error {
+	return parseItems(args, opts, true)
+}
 
-			var o any
-			if err := json.Unmarshal([]byte(v), &o); err != nil {
-				return fmt.Errorf("'%s' is not a valid json", v)
+func parseItems(args []string, opts *Options, interpolated bool) error {
+	for _, arg := range args {
+		if interpolated {
+			var err error
+			if arg, err = interpolate(arg, escapeItem); err != nil {
+				return err
 			}
-			opts.Data[k] = o
-		case separatorQueryParam:
-			opts.QueryParams.Add(k, v)
-		default:
-			return fmt.Errorf("'%s' is not a valid request item", arg)
 		}
+		if err := parseItem(arg, opts); err != nil {
+			return err
+		}
+	}
+	if opts.RawBody != nil && (len(opts.Data) > 0 || len(opts.FormData) > 0 || len(opts.Files) > 0) {
+		return fmt.Errorf("cannot combine a raw request body with data items")
+	}
+
+	return nil
+}
+
+func parseItem(arg string, opts *Options) error {
+	k, sep, v := splitItem(arg)
+	logrus.Debugf("separator: '%s', key: '%s', value: '%s'", sep, k, v)
+
+	switch sep {
+	case separatorHeaderFile, separatorQueryParamFile, separatorDataStringFile, separatorDataRawJSONFile:
+		content, err := readItemFile(v)
+		if err != nil {
+			return err
+		}
+		v = content
+	}
+
+	switch sep {
+	case separatorHeader, separatorHeaderFile:
+		if sep == separatorHeader && v == "" {
+			// "Header:" removes the header, including the default ones.
+			opts.Header[http.CanonicalHeaderKey(k)] = nil
+			return nil
+		}
+		opts.Header.Add(k, strings.TrimRight(v, "\r\n"))
+	case separatorHeaderEmpty:
+		if v != "" {
+			return fmt.Errorf("'%s' is not a valid request item, use '%s;' to send an empty header", arg, k)
+		}
+		opts.Header.Add(k, "")
+	case separatorDataString, separatorDataStringFile:
+		if opts.IsForm || opts.IsMultipart {
+			opts.FormData.Add(k, v)
+			return nil
+		}
+		return setNested(opts.Data, k, v)
+	case separatorDataRawJSON, separatorDataRawJSONFile:
+		if opts.IsForm || opts.IsMultipart {
+			return fmt.Errorf("cannot use json value type '%s' with --form", arg)
+		}
+
+		var o any
+		if err := json.Unmarshal([]byte(v), &o); err != nil {
+			return fmt.Errorf("'%s' is not a valid json", v)
+		}
+		return setNested(opts.Data, k, o)
+	case separatorFileUpload:
+		if !opts.IsForm && !opts.IsMultipart {
+			return fmt.Errorf("cannot upload file '%s' without --form or --multipart", arg)
+		}
+		f, err := parseFormFile(k, v)
+		if err != nil {
+			return err
+		}
+		opts.Files = append(opts.Files, f)
+	case separatorQueryParam, separatorQueryParamFile:
+		opts.QueryParams.Add(k, strings.TrimRight(v, "\r\n"))
+	default:
+		return fmt.Errorf("'%s' is not a valid request item", arg)
 	}
 
 	return nil
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/md5" // #nosec G501 -- MD5 is part of the digest scheme.
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const (
+	authorizationHeader   = "Authorization"
+	wwwAuthenticateHeader = "WWW-Authenticate"
+
+	authTypeBasic  = "basic"
+	authTypeBearer = "bearer"
+	authTypeDigest = "digest"
+)
+
+var authTypes = []string{
+	authTypeBasic,
+	authTypeBearer,
+	authTypeDigest,
+}
+
+// validateAuth checks the --auth and --auth-type values.
+func validateAuth(opts *Options) error {
+	if !slices.Contains(authTypes, opts.AuthType) {
+		return fmt.Errorf("unknown auth type '%s', must be one of: %s", opts.AuthType, strings.Join(authTypes, ", "))
+	}
+	if opts.Auth != "" && opts.AuthType != authTypeBearer && !strings.Contains(opts.Auth, ":") {
+		return fmt.Errorf("'%s' is not a valid credential, use user:password", opts.Auth)
+	}
+
+	return nil
+}
+
+// authorization returns the Authorization header value for the schemes that
+// do not need a challenge.
+func authorization(opts *Options) string {
+	if opts.Auth == "" {
+		return ""
+	}
+
+	switch opts.AuthType {
+	case authTypeBasic:
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(opts.Auth))
+	case authTypeBearer:
+		return "Bearer " + opts.Auth
+	}
+
+	return ""
+}
+
+// netrcAuth returns the "login:password" credential of host in the netrc
+// file, or "" if there is none. The file is $NETRC or ~/.netrc.
+func netrcAuth(host string) (string, error) {
+	path := os.Getenv("NETRC")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", nil
+		}
+		path = filepath.Join(home, ".netrc")
+	}
+
+	f, err := os.Open(filepath.Clean(path))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	defer close(f)
+
+	// the file is a sequence of tokens: "machine NAME", "default", "login
+	// NAME", "password SECRET" and "macdef NAME" followed by a macro
+	// definition that ends with an empty line.
+	var (
+		tokens []string
+		macro  bool
+	)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if macro {
+			macro = strings.TrimSpace(line) != ""
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) > 0 && strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		for i, field := range fields {
+			if field == "macdef" {
+				fields = fields[:i]
+				macro = true
+				break
+			}
+		}
+		tokens = append(tokens, fields...)
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+
+	var (
+		entries []netrcEntry
+		entry   *netrcEntry
+	)
+	for i := 0; i < len(tokens); i++ {
+		next := ""
+		if i+1 < len(tokens) {
+			next = tokens[i+1]
+		}
+		switch tokens[i] {
+		case "machine":
+			entries = append(entries, netrcEntry{machine: next})
+			entry = &entries[len(entries)-1]
+			i++
+		case "default":
+			entries = append(entries, netrcEntry{isDefault: true})
+			entry = &entries[len(entries)-1]
+		case "login", "password", "account":
+			if entry != nil && tokens[i] == "login" {
+				entry.login = next
+			}
+			if entry != nil && tokens[i] == "password" {
+				entry.password = next
+			}
+			i++
+		}
+	}
+
+	for _, e := range entries {
+		if e.machine == host {
+			return e.login + ":" + e.password, nil
+		}
+	}
+	for _, e := range entries {
+		if e.isDefault {
+			return e.login + ":" + e.password, nil
+		}
+	}
+
+	return "", nil
+}
+
+type netrcEntry struct {
+	machine   string
+	isDefault bool
+	login     string
+	password  string
+}
+
+// digestChallenge sends the request without credentials, and returns its
+// result and the Authorization header value answering the digest challenge
+// of the response. The authorization is empty when the server does not
+// send a digest challenge.
+func digestChallenge(ctx context.Context, cli *http.Client, opts *Options) (*Result, string, error) {
+	req, err := newRequest(ctx, opts)
+	if err != nil {
+		return nil, "", err
+	}
+	r, err := traceRequest(cli, req, opts)
+	if err != nil {
+		return nil, "", err
+	}
+	if r.Status != "401" {
+		return r, "", nil
+	}
+
+	for _, h := range r.Headers {
+		if h.Name != http.CanonicalHeaderKey(wwwAuthenticateHeader) {
+			continue
+		}
+		c, ok := parseDigestChallenge(h.Value)
+		if !ok {
+			continue
+		}
+		b := make([]byte, 16)
+		if _, err := rand.Read(b); err != nil {
+			return nil, "", err
+		}
+		username, password, _ := strings.Cut(opts.Auth, ":")
+		authorization, err := c.authorization(username, password, opts.Method, req.URL.RequestURI(), hex.EncodeToString(b))
+		if err != nil {
+			return nil, "", err
+		}
+
+		return r, authorization, nil
+	}
+
+	return r, "", nil
+}
+
+// digest is a "WWW-Authenticate: Digest ..." challenge as defined by
+// RFC 7616.
+type digest struct {
+	params map[string]string
+}
+
+// parseDigestChallenge parses the value of a WWW-Authenticate header.
+func parseDigestChallenge(s string) (*digest, bool) {
+	scheme, rest, _ := strings.Cut(strings.TrimSpace(s), " ")
+	if !strings.EqualFold(scheme, "digest") {
+		return nil, false
+	}
+
+	d := &digest{params: map[string]string{}}
+	for rest = strings.TrimSpace(rest); rest != ""; rest = strings.TrimLeft(rest, " ,") {
+		key, value, ok := strings.Cut(rest, "=")
+		if !ok {
+			break
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		if strings.HasPrefix(value, `"`) {
+			var b strings.Builder
+			i := 1
+			for ; i < len(value) && value[i] != '"'; i++ {
+				if value[i] == '\\' && i+1 < len(value) {
+					i++
+				}
+				b.WriteByte(value[i])
+			}
+			d.params[key] = b.String()
+			rest = value[min(i+1, len(value)):]
+		} else {
+			v, after, _ := strings.Cut(value, ",")
+			d.params[key] = strings.TrimSpace(v)
+			rest = after
+		}
+	}
+
+	return d, d.params["nonce"] != ""
+}
+
+func (d *digest) hash() (func() hash.Hash, error) {
+	switch strings.TrimSuffix(strings.ToUpper(d.params["algorithm"]), "-SESS") {
+	case "", "MD5":
+		return md5.New, nil
+	case "SHA-256":
+		return sha256.New, nil
+	case "SHA-512-256":
+		return sha512.New512_256, nil
+	}
+
+	return nil, fmt.Errorf("unsupported digest algorithm '%s'", d.params["algorithm"])
+}
+
+// authorization returns the Authorization header value answering the
+// challenge for the given request.
+func (d *digest) authorization(username, password, method, uri, cnonce string) (string, error) {
+	newHash, err := d.hash()
+	if err != nil {
+		return "", err
+	}
+	h := func(s string) string {
+		sum := newHash()
+		sum.Write([]byte(s))
+		return hex.EncodeToString(sum.Sum(nil))
+	}
+
+	nonce := d.params["nonce"]
+	realm := d.params["realm"]
+	nc := "00000001"
+
+	ha1 := h(username + ":" + realm + ":" + password)
+	if strings.HasSuffix(strings.ToUpper(d.params["algorithm"]), "-SESS") {
+		ha1 = h(ha1 + ":" + nonce + ":" + cnonce)
+	}
+	ha2 := h(method + ":" + uri)
+
+	qop := ""
+	for _, v := range strings.Split(d.params["qop"], ",") {
+		if strings.TrimSpace(v) == "auth" {
+			qop = "auth"
+		}
+	}
+	if d.params["qop"] != "" && qop == "" {
+		return "", fmt.Errorf("unsupported digest qop '%s'", d.params["qop"])
+	}
+
+	params := []string{
+		fmt.Sprintf(`username="%s"`, username),
+		fmt.Sprintf(`realm="%s"`, realm),
+		fmt.Sprintf(`nonce="%s"`, nonce),
+		fmt.Sprintf(`uri="%s"`, uri),
+	}
+	if qop == "" {
+		params = append(params, fmt.Sprintf(`response="%s"`, h(ha1+":"+nonce+":"+ha2)))
+	} else {
+		params = append(params,
+			fmt.Sprintf(`response="%s"`, h(ha1+":"+nonce+":"+nc+":"+cnonce+":"+qop+":"+ha2)),
+			"qop="+qop,
+			"nc="+nc,
+			fmt.Sprintf(`cnonce="%s"`, cnonce),
+		)
+	}
+	if algorithm := d.params["algorithm"]; algorithm != "" {
+		params = append(params, "algorithm="+algorithm)
+	}
+	if opaque := d.params["opaque"]; opaque != "" {
+		params = append(params, fmt.Sprintf(`opaque="%s"`, opaque))
+	}
+
+	return "Digest " + strings.Join(params, ", "), nil
+}
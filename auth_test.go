@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"crypto/md5" // #nosec G501 -- MD5 is part of the digest scheme.
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthorization(t *testing.T) {
+	opts := NewDefaultOptions()
+	assert.Empty(t, authorization(opts))
+
+	opts.Auth = "user:pass"
+	assert.Equal(t, "Basic dXNlcjpwYXNz", authorization(opts))
+
+	opts.Auth = "token"
+	opts.AuthType = authTypeBearer
+	assert.Equal(t, "Bearer token", authorization(opts))
+
+	opts.AuthType = authTypeDigest
+	assert.Empty(t, authorization(opts))
+}
+
+func TestValidateAuth(t *testing.T) {
+	opts := NewDefaultOptions()
+	opts.Auth = "user"
+	require.Error(t, validateAuth(opts))
+
+	opts.AuthType = "ntlm"
+	require.Error(t, validateAuth(opts))
+
+	opts.AuthType = authTypeBearer
+	require.NoError(t, validateAuth(opts))
+}
+
+func TestNetrcAuth(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "netrc")
+	content := `# comment
+machine api.example.com
+  login alice
+  password secret
+
+macdef init
+machine evil.example.com login mallory password nope
+
+machine other.example.com login bob password hunter2
+default login anonymous password guest
+`
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	t.Setenv("NETRC", path)
+
+	cases := map[string]string{
+		"api.example.com":   "alice:secret",
+		"other.example.com": "bob:hunter2",
+		"evil.example.com":  "anonymous:guest",
+		"unknown.com":       "anonymous:guest",
+	}
+	for host, want := range cases {
+		got, err := netrcAuth(host)
+		require.NoError(t, err)
+		assert.Equal(t, want, got, host)
+	}
+}
+
+func TestNetrcAuth_missingFile(t *testing.T) {
+	t.Setenv("NETRC", filepath.Join(t.TempDir(), "missing"))
+
+	got, err := netrcAuth("api.example.com")
+	require.NoError(t, err)
+	assert.Empty(t, got)
+}
+
+func TestDigest_authorization(t *testing.T) {
+	// the example of RFC 2617, section 3.5.
+	d, ok := parseDigestChallenge(`Digest realm="testrealm@host.com", qop="auth,auth-int", nonce="dcd98b7102dd2f0e8b11d0f600bfb0c093", opaque="5ccc069c403ebaf9f0171e9517f40e41"`)
+	require.True(t, ok)
+
+	got, err := d.authorization("Mufasa", "Circle Of Life", http.MethodGet, "/dir/index.html", "0a4f113b")
+
+	require.NoError(t, err)
+	assert.Equal(t, `Digest username="Mufasa", realm="testrealm@host.com", nonce="dcd98b7102dd2f0e8b11d0f600bfb0c093", uri="/dir/index.html", response="6629fae49393a05397450978507c4ef1", qop=auth, nc=00000001, cnonce="0a4f113b", opaque="5ccc069c403ebaf9f0171e9517f40e41"`, got)
+}
+
+func TestParseDigestChallenge_notDigest(t *testing.T) {
+	_, ok := parseDigestChallenge(`Basic realm="x"`)
+	assert.False(t, ok)
+}
+
+func md5hex(s string) string {
+	sum := md5.Sum([]byte(s)) // #nosec G401
+	return hex.EncodeToString(sum[:])
+}
+
+func TestTrace_digest(t *testing.T) {
+	const nonce = "abc"
+	svr := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		d, ok := parseDigestChallenge(req.Header.Get("Authorization"))
+		if !ok {
+			rw.Header().Set("WWW-Authenticate", fmt.Sprintf(`Digest realm="test", qop="auth", nonce="%s"`, nonce))
+			rw.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		ha1 := md5hex("user:test:pass")
+		ha2 := md5hex(req.Method + ":" + d.params["uri"])
+		want := md5hex(ha1 + ":" + nonce + ":" + d.params["nc"] + ":" + d.params["cnonce"] + ":auth:" + ha2)
+		assert.Equal(t, want, d.params["response"])
+		assert.Equal(t, "/path?k=v", d.params["uri"])
+	}))
+	defer svr.Close()
+
+	opts := NewDefaultOptions()
+	opts.URL = svr.URL + "/path"
+	opts.QueryParams.Set("k", "v")
+	opts.Auth = "user:pass"
+	opts.AuthType = authTypeDigest
+	r, err := Trace(context.Background(), opts)
+
+	require.NoError(t, err)
+	assert.Equal(t, "200", r.Status)
+	require.Len(t, r.Hops, 1)
+	assert.Equal(t, "digest challenge", r.Hops[0].Name)
+	assert.Equal(t, "401", r.Hops[0].Result.Status)
+	assert.Empty(t, opts.Header.Get("Authorization"))
+}
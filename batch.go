@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// targetFormats are the formats of a targets file.
+var targetFormats = []string{"lines", "vegeta"}
+
+// Target is a request of a batch: a line of a targets file, with the
+// arguments of the command line, like "POST example.com/items id:=1".
+type Target struct {
+	Name string
+	Args []string
+	// Method, Header and Body are set by the formats without request items,
+	// like Vegeta targets. The request then only has the headers of the
+	// target.
+	Method string
+	Header http.Header
+	Body   []byte
+}
+
+// apply updates o with the target and the request items.
+func (t Target) apply(o *Options, items []string) error {
+	if t.Method != "" {
+		o.Method = t.Method
+		o.explicitMethod = true
+	}
+	if t.Header != nil {
+		for _, name := range []string{acceptHeader, contentTypeHeader} {
+			o.Header[name] = nil
+		}
+		for name, values := range t.Header {
+			o.Header[name] = values
+		}
+	}
+	if t.Body != nil {
+		o.RawBody = t.Body
+	}
+
+	return ParseArgs(append(slices.Clip(t.Args), items...), o)
+}
+
+// ReadTargetsFormat reads the targets of r in format.
+func ReadTargetsFormat(r io.Reader, format string) ([]Target, error) {
+	switch format {
+	case "", "lines":
+		return ReadTargets(r)
+	case "vegeta":
+		return ReadVegetaTargets(r)
+	}
+
+	return nil, fmt.Errorf("unknown targets format '%s', must be one of: %s", format, strings.Join(targetFormats, ", "))
+}
+
+// ReadTargets reads the targets of r, one per line. Empty lines and lines
+// starting with "#" are skipped.
+func ReadTargets(r io.Reader) ([]Target, error) {
+	var targets []Target
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		args, err := splitShellWords(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+		targets = append(targets, Target{Name: line, Args: args})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no targets")
+	}
+
+	return targets, nil
+}
+
+// ReadVegetaTargets reads targets in the HTTP format of Vegeta: a request
+// line, like "GET https://example.com", header lines and an optional
+// "@path" body line, targets being separated by empty lines.
+func ReadVegetaTargets(r io.Reader) ([]Target, error) {
+	var targets []Target
+	var t *Target
+	flush := func() {
+		if t != nil {
+			targets = append(targets, *t)
+		}
+		t = nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+			flush()
+		case strings.HasPrefix(line, "#"):
+		case t == nil:
+			method, u, ok := strings.Cut(line, " ")
+			u = strings.TrimSpace(u)
+			if !ok || u == "" || !isToken(method) {
+				return nil, fmt.Errorf("line %d: invalid target '%s', use METHOD URL", lineNum, line)
+			}
+			t = &Target{Name: method + " " + u, Args: []string{u}, Method: method, Header: http.Header{}}
+		case t.Body != nil:
+			return nil, fmt.Errorf("line %d: the body must be the last line of a target", lineNum)
+		case strings.HasPrefix(line, "@"):
+			b, err := os.ReadFile(filepath.Clean(line[1:]))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNum, err)
+			}
+			t.Body = b
+		default:
+			name, value, ok := strings.Cut(line, ":")
+			if !ok || !isToken(strings.TrimSpace(name)) {
+				return nil, fmt.Errorf("line %d: invalid header '%s'", lineNum, line)
+			}
+			t.Header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no targets")
+	}
+
+	return targets, nil
+}
+
+// RunTargets traces the targets with at most concurrency requests at a
+// time, and returns a report entry for each, in the order of targets. The
+// options of a target are a clone of base, updated by prepare.
+func RunTargets(ctx context.Context, targets []Target, base *Options, concurrency int, prepare func(*Options, Target) error) []ReportEntry {
+	entries := make([]ReportEntry, len(targets))
+	sem := make(chan struct{}, max(concurrency, 1))
+	var wg sync.WaitGroup
+	for i, t := range targets {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			e := ReportEntry{Name: t.Name}
+			opts := base.clone()
+			err := prepare(opts, t)
+			if err == nil {
+				e.Result, err = Trace(ctx, opts)
+			}
+			e.Err = err
+			entries[i] = e
+		}()
+	}
+	wg.Wait()
+
+	return entries
+}
+
+// sortByTotal sorts entries by total time, the failed ones last.
+func sortByTotal(entries []ReportEntry) {
+	slices.SortStableFunc(entries, func(a, b ReportEntry) int {
+		switch {
+		case a.Result == nil && b.Result == nil:
+			return 0
+		case a.Result == nil:
+			return 1
+		case b.Result == nil:
+			return -1
+		}
+		return cmp.Compare(a.Result.Total(), b.Result.Total())
+	})
+}
+
+// PrintDetails prints the result of each entry, like PrintResult, under a
+// "### name" heading.
+func PrintDetails(entries []ReportEntry, opts ...PrintOption) error {
+	options := &printOptions{
+		out:   os.Stdout,
+		color: true,
+	}
+	for _, o := range opts {
+		o(options)
+	}
+	green := green
+	if !options.color {
+		green = noColor
+	}
+
+	for _, e := range entries {
+		if e.Result == nil {
+			continue
+		}
+		fmt.Fprintf(options.out, "\n%s %s\n\n", green("###"), green(e.Name))
+		if err := PrintResult(e.Result, opts...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// isTerminal reports whether w is a terminal.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// terminalOptions returns the options printing to w, without colors unless
+// w is a terminal.
+func terminalOptions(w io.Writer) []PrintOption {
+	opts := []PrintOption{WithOut(w)}
+	if !isTerminal(w) {
+		opts = append(opts, WithNoColor())
+	}
+
+	return opts
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadTargets(t *testing.T) {
+	targets, err := ReadTargets(strings.NewReader(`
+# health checks
+example.com/health
+  POST example.com/items 'name=a b' X-Token:1
+
+`))
+
+	require.NoError(t, err)
+	assert.Equal(t, []Target{
+		{Name: "example.com/health", Args: []string{"example.com/health"}},
+		{Name: "POST example.com/items 'name=a b' X-Token:1", Args: []string{"POST", "example.com/items", "name=a b", "X-Token:1"}},
+	}, targets)
+
+	_, err = ReadTargets(strings.NewReader("# nothing\n"))
+	assert.EqualError(t, err, "no targets")
+}
+
+func TestRunTargets(t *testing.T) {
+	var running, maxRunning atomic.Int32
+	svr := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			m := maxRunning.Load()
+			if n <= m || maxRunning.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		assert.Equal(t, "1", req.Header.Get("X-Batch"))
+		if req.URL.Path == "/missing" {
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer svr.Close()
+
+	var targets []Target
+	for _, path := range []string{"/a", "/b", "/missing", "/c", "/d"} {
+		targets = append(targets, Target{Name: path, Args: []string{svr.URL + path}})
+	}
+	targets = append(targets, Target{Name: "invalid", Args: []string{svr.URL, "invalid"}})
+
+	entries := RunTargets(context.Background(), targets, NewDefaultOptions(), 2, func(o *Options, t Target) error {
+		return ParseArgs(append(t.Args, "X-Batch:1"), o)
+	})
+
+	require.Len(t, entries, 6)
+	assert.Equal(t, "/missing", entries[2].Name)
+	assert.Equal(t, "404", entries[2].Result.Status)
+	assert.EqualError(t, entries[5].Err, "'invalid' is not a valid request item")
+	assert.LessOrEqual(t, maxRunning.Load(), int32(2))
+}
+
+func TestSortByTotal(t *testing.T) {
+	entries := []ReportEntry{
+		{Name: "failed", Err: errors.New("timeout")},
+		{Name: "slow", Result: &Result{MetricServerProcessing: 300}},
+		{Name: "fast", Result: &Result{MetricServerProcessing: 10}},
+	}
+
+	sortByTotal(entries)
+
+	assert.Equal(t, []string{"fast", "slow", "failed"}, []string{entries[0].Name, entries[1].Name, entries[2].Name})
+}
+
+func TestPrintDetails(t *testing.T) {
+	entries := []ReportEntry{
+		{Name: "example.com", Result: &Result{URL: "http://example.com", Status: "200", HTTPVersion: "HTTP/1.1", Output: "testdata/response_body.txt"}},
+		{Name: "failed", Err: errors.New("timeout")},
+	}
+	var out bytes.Buffer
+
+	err := PrintDetails(entries, WithOut(&out), WithNoColor())
+
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(out.String(), "\n### example.com\n\n"))
+	assert.NotContains(t, out.String(), "failed")
+}
+
+func TestReadVegetaTargets(t *testing.T) {
+	targets, err := ReadTargetsFormat(strings.NewReader(`
+# vegeta targets
+GET https://example.com/health
+X-Token: 1
+X-Token: 2
+
+POST https://example.com/items
+Content-Type: application/json
+@testdata/item.json
+`), "vegeta")
+
+	require.NoError(t, err)
+	require.Len(t, targets, 2)
+	assert.Equal(t, Target{
+		Name:   "GET https://example.com/health",
+		Args:   []string{"https://example.com/health"},
+		Method: "GET",
+		Header: http.Header{"X-Token": {"1", "2"}},
+	}, targets[0])
+	assert.Equal(t, "POST", targets[1].Method)
+	assert.Equal(t, "{\"k\": [1, 2]}\n", string(targets[1].Body))
+
+	cases := []struct {
+		content string
+		err     string
+	}{
+		{"https://example.com", "line 1: invalid target 'https://example.com', use METHOD URL"},
+		{"GET https://example.com\nnot a header", "line 2: invalid header 'not a header'"},
+		{"POST https://example.com\n@testdata/item.json\nX-Token: 1", "line 3: the body must be the last line of a target"},
+		{"# nothing", "no targets"},
+	}
+	for _, c := range cases {
+		_, err := ReadVegetaTargets(strings.NewReader(c.content))
+		assert.EqualError(t, err, c.err)
+	}
+
+	_, err = ReadTargetsFormat(strings.NewReader(""), "har")
+	assert.EqualError(t, err, "unknown targets format 'har', must be one of: lines, vegeta")
+}
+
+func TestTarget_apply(t *testing.T) {
+	target := Target{
+		Args:   []string{"https://example.com/items"},
+		Method: "PUT",
+		Header: http.Header{"X-Token": {"1"}},
+		Body:   []byte("raw"),
+	}
+	opts := NewDefaultOptions()
+	opts.Method = "DELETE"
+	opts.explicitMethod = true
+
+	err := target.apply(opts, []string{"X-Extra:1"})
+
+	require.NoError(t, err)
+	assert.Equal(t, "PUT", opts.Method)
+	assert.Equal(t, "https://example.com/items", opts.URL)
+	assert.Equal(t, "raw", string(opts.RawBody))
+	assert.Equal(t, http.Header{"Accept": nil, "Content-Type": nil, "X-Token": {"1"}, "X-Extra": {"1"}}, opts.Header)
+}
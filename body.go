@@ -0,0 +1,37 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// readRawBody returns the body described by the value of --data-binary:
+// "@path" reads a file, "@-" reads r, anything else is sent as is.
+func readRawBody(value string, r io.Reader) ([]byte, error) {
+	path, ok := strings.CutPrefix(value, "@")
+	if !ok {
+		return []byte(value), nil
+	}
+	if path == "-" {
+		return io.ReadAll(r)
+	}
+
+	return os.ReadFile(filepath.Clean(path))
+}
+
+// hasPipedInput reports whether r is a pipe or a regular file rather than a
+// terminal, meaning its content can be used as the request body.
+func hasPipedInput(r io.Reader) bool {
+	f, ok := r.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice == 0
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadRawBody(t *testing.T) {
+	b, err := readRawBody("[1, 2]", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "[1, 2]", string(b))
+
+	b, err = readRawBody("@testdata/item.json", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "{\"k\": [1, 2]}\n", string(b))
+
+	b, err = readRawBody("@-", strings.NewReader("<xml/>"))
+	require.NoError(t, err)
+	assert.Equal(t, "<xml/>", string(b))
+
+	_, err = readRawBody("@testdata/missing.txt", nil)
+	require.Error(t, err)
+}
+
+func TestHasPipedInput(t *testing.T) {
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	defer close(r)
+	defer close(w)
+
+	assert.True(t, hasPipedInput(r))
+	assert.False(t, hasPipedInput(strings.NewReader("")))
+}
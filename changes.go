@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Change is a difference between two consecutive results.
+type Change struct {
+	What string `json:"what"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s changed from %s to %s", c.What, orNone(c.From), orNone(c.To))
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+
+	return s
+}
+
+// ChangeDetector compares each result with the previous one: the status,
+// the body, the certificate and the resolved IPs, and optionally headers and
+// the total time.
+type ChangeDetector struct {
+	// Headers are the response headers compared.
+	Headers []string
+	// LatencyThreshold is the difference of total time that is a change,
+	// none if zero.
+	LatencyThreshold time.Duration
+
+	prev *changeSnapshot
+}
+
+// changeSnapshot is what is compared of a result.
+type changeSnapshot struct {
+	status          string
+	headers         []string
+	bodyHash        string
+	certFingerprint string
+	ips             string
+	total           int64
+}
+
+func (d *ChangeDetector) snapshot(r *Result, err error) (*changeSnapshot, error) {
+	if err != nil {
+		return &changeSnapshot{status: "error"}, nil
+	}
+
+	s := &changeSnapshot{
+		status:          r.Status,
+		certFingerprint: r.CertFingerprint,
+		total:           r.Total(),
+	}
+	for _, name := range d.Headers {
+		s.headers = append(s.headers, r.Header(name))
+	}
+	ips := slices.Clone(r.ResolvedIPs)
+	slices.Sort(ips)
+	s.ips = strings.Join(ips, ", ")
+
+	f, err := os.Open(r.Output)
+	if err != nil {
+		return nil, err
+	}
+	defer close(f)
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	s.bodyHash = hex.EncodeToString(h.Sum(nil))[:16]
+
+	return s, nil
+}
+
+// Detect returns the changes between the result of a run, r or err, and the
+// previous one. Only the status is compared with a failed run, and the IPs
+// are only compared when both runs looked them up.
+func (d *ChangeDetector) Detect(r *Result, err error) ([]Change, error) {
+	s, err := d.snapshot(r, err)
+	if err != nil {
+		return nil, err
+	}
+	prev := d.prev
+	d.prev = s
+	if prev == nil {
+		return nil, nil
+	}
+
+	var changes []Change
+	add := func(what, from, to string) {
+		if from != to {
+			changes = append(changes, Change{What: what, From: from, To: to})
+		}
+	}
+	add("status", prev.status, s.status)
+	if prev.status == "error" || s.status == "error" {
+		return changes, nil
+	}
+	for i, name := range d.Headers {
+		add("header "+http.CanonicalHeaderKey(name), prev.headers[i], s.headers[i])
+	}
+	add("body", prev.bodyHash, s.bodyHash)
+	add("certificate", prev.certFingerprint, s.certFingerprint)
+	if prev.ips != "" && s.ips != "" {
+		add("resolved IPs", prev.ips, s.ips)
+	}
+	diff := time.Duration(s.total-prev.total) * time.Millisecond
+	if d.LatencyThreshold > 0 && (diff >= d.LatencyThreshold || -diff >= d.LatencyThreshold) {
+		changes = append(changes, Change{What: "total time", From: fmtms(prev.total), To: fmtms(s.total)})
+	}
+
+	return changes, nil
+}
+
+// Notification is sent when the result of a request changed.
+type Notification struct {
+	URL     string    `json:"url"`
+	Time    time.Time `json:"time"`
+	Status  string    `json:"status"`
+	Changes []Change  `json:"changes"`
+}
+
+// Title returns the title of n, like "example.com/health changed".
+func (n Notification) Title() string {
+	return "httpcheck: " + n.URL + " changed"
+}
+
+// Text returns the changes of n, one per line.
+func (n Notification) Text() string {
+	lines := make([]string, len(n.Changes))
+	for i, c := range n.Changes {
+		lines[i] = c.String()
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Notifier sends notifications.
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+// notifyTimeout is how long sending a notification may take.
+const notifyTimeout = 10 * time.Second
+
+// notifyContext returns ctx limited to timeout, or to notifyTimeout if it
+// is zero, so that a notifier that does not respond never stalls the watch.
+func notifyContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout == 0 {
+		timeout = notifyTimeout
+	}
+
+	return context.WithTimeout(ctx, timeout)
+}
+
+// notifyCommand returns the command of a notification, killed at the end of
+// ctx. The output is read until then, even if the command started others
+// that still hold it.
+func notifyCommand(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.WaitDelay = time.Second
+
+	return cmd
+}
+
+// CommandNotifier runs a shell command within Timeout or notifyTimeout. The
+// notification is the JSON input of the command, and its title and text are
+// in the HTTPCHECK_TITLE and HTTPCHECK_CHANGES environment variables.
+type CommandNotifier struct {
+	Command string
+	Timeout time.Duration
+}
+
+// Notify implements Notifier.
+func (c CommandNotifier) Notify(ctx context.Context, n Notification) error {
+	ctx, cancel := notifyContext(ctx, c.Timeout)
+	defer cancel()
+
+	b, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	cmd := notifyCommand(ctx, "sh", "-c", c.Command) // #nosec G204 -- the command is given by the user.
+	cmd.Stdin = bytes.NewReader(b)
+	cmd.Env = append(os.Environ(), "HTTPCHECK_URL="+n.URL, "HTTPCHECK_TITLE="+n.Title(), "HTTPCHECK_CHANGES="+n.Text())
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("notify command failed: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
+
+// WebhookNotifier posts the notification as JSON to a URL, within Timeout
+// or notifyTimeout.
+type WebhookNotifier struct {
+	URL     string
+	Timeout time.Duration
+}
+
+// Notify implements Notifier.
+func (w WebhookNotifier) Notify(ctx context.Context, n Notification) error {
+	ctx, cancel := notifyContext(ctx, w.Timeout)
+	defer cancel()
+
+	b, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set(contentTypeHeader, contentTypeJSON)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("notify webhook failed: %w", err)
+	}
+	defer close(resp.Body)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("notify webhook failed with status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// DesktopNotifier shows a desktop notification, with notify-send on Linux
+// and osascript on macOS, within Timeout or notifyTimeout.
+type DesktopNotifier struct {
+	Timeout time.Duration
+}
+
+// Notify implements Notifier.
+func (d DesktopNotifier) Notify(ctx context.Context, n Notification) error {
+	ctx, cancel := notifyContext(ctx, d.Timeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		script := fmt.Sprintf("display notification %s with title %s", appleScriptQuote(n.Text()), appleScriptQuote(n.Title()))
+		cmd = notifyCommand(ctx, "osascript", "-e", script)
+	case "linux", "freebsd", "openbsd", "netbsd":
+		cmd = notifyCommand(ctx, "notify-send", n.Title(), n.Text())
+	default:
+		return fmt.Errorf("desktop notifications are not supported on %s", runtime.GOOS)
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("desktop notification failed: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
+
+// appleScriptQuote returns s as an AppleScript string.
+func appleScriptQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func changeResult(t *testing.T, status, body string, total int64) *Result {
+	t.Helper()
+	output := filepath.Join(t.TempDir(), "body")
+	require.NoError(t, os.WriteFile(output, []byte(body), 0o600))
+
+	return &Result{
+		Status:                 status,
+		Output:                 output,
+		Headers:                []Header{{Name: "Etag", Value: `"` + body + `"`}},
+		CertFingerprint:        "ab12",
+		ResolvedIPs:            []string{"10.0.0.2", "10.0.0.1"},
+		MetricServerProcessing: total,
+	}
+}
+
+func TestChangeDetector_Detect(t *testing.T) {
+	d := &ChangeDetector{Headers: []string{"etag"}, LatencyThreshold: 100 * time.Millisecond}
+
+	changes, err := d.Detect(changeResult(t, "200", "a", 50), nil)
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+
+	changes, err = d.Detect(changeResult(t, "200", "a", 120), nil)
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+
+	r := changeResult(t, "503", "b", 300)
+	r.CertFingerprint = "cd34"
+	r.ResolvedIPs = []string{"10.0.0.3"}
+	changes, err = d.Detect(r, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []Change{
+		{What: "status", From: "200", To: "503"},
+		{What: "header Etag", From: `"a"`, To: `"b"`},
+		{What: "body", From: "ca978112ca1bbdca", To: "3e23e8160039594a"},
+		{What: "certificate", From: "ab12", To: "cd34"},
+		{What: "resolved IPs", From: "10.0.0.1, 10.0.0.2", To: "10.0.0.3"},
+		{What: "total time", From: "120ms", To: "300ms"},
+	}, changes)
+
+	changes, err = d.Detect(nil, errors.New("connection refused"))
+	require.NoError(t, err)
+	assert.Equal(t, []Change{{What: "status", From: "503", To: "error"}}, changes)
+}
+
+func TestChangeDetector_Detect_withoutLookup(t *testing.T) {
+	d := &ChangeDetector{}
+	_, err := d.Detect(changeResult(t, "200", "a", 50), nil)
+	require.NoError(t, err)
+
+	// a reused connection does not look up the host again.
+	r := changeResult(t, "200", "a", 500)
+	r.ResolvedIPs = nil
+	changes, err := d.Detect(r, nil)
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+}
+
+func TestChange_String(t *testing.T) {
+	assert.Equal(t, "status changed from 200 to 503", Change{What: "status", From: "200", To: "503"}.String())
+	assert.Equal(t, "header X-Version changed from none to 2", Change{What: "header X-Version", To: "2"}.String())
+}
+
+var testNotification = Notification{
+	URL:     "http://example.com/health",
+	Time:    time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+	Status:  "503",
+	Changes: []Change{{What: "status", From: "200", To: "503"}},
+}
+
+func TestCommandNotifier_Notify(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	n := CommandNotifier{Command: `echo "$HTTPCHECK_TITLE: $HTTPCHECK_CHANGES" > ` + out + ` && cat >> ` + out}
+	require.NoError(t, n.Notify(context.Background(), testNotification))
+
+	b, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Equal(t, "httpcheck: http://example.com/health changed: status changed from 200 to 503\n"+
+		`{"url":"http://example.com/health","time":"2024-05-01T10:00:00Z","status":"503","changes":[{"what":"status","from":"200","to":"503"}]}`, string(b))
+
+	err = CommandNotifier{Command: "echo oops >&2; exit 3"}.Notify(context.Background(), testNotification)
+	assert.EqualError(t, err, "notify command failed: exit status 3: oops")
+}
+
+func TestCommandNotifier_Notify_timeout(t *testing.T) {
+	start := time.Now()
+
+	err := CommandNotifier{Command: "sleep 10", Timeout: 50 * time.Millisecond}.Notify(context.Background(), testNotification)
+
+	assert.ErrorContains(t, err, "notify command failed")
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestWebhookNotifier_Notify(t *testing.T) {
+	var got Notification
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, contentTypeJSON, r.Header.Get(contentTypeHeader))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer svr.Close()
+
+	require.NoError(t, WebhookNotifier{URL: svr.URL}.Notify(context.Background(), testNotification))
+	assert.Equal(t, testNotification, got)
+
+	err := WebhookNotifier{URL: svr.URL + "/fail"}.Notify(context.Background(), testNotification)
+	assert.EqualError(t, err, "notify webhook failed with status 502")
+}
+
+func TestWebhookNotifier_Notify_timeout(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the request is canceled once its body is read and the client is gone.
+		_, _ = io.Copy(io.Discard, r.Body)
+		<-r.Context().Done()
+	}))
+	defer svr.Close()
+
+	err := WebhookNotifier{URL: svr.URL, Timeout: 50 * time.Millisecond}.Notify(context.Background(), testNotification)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestAppleScriptQuote(t *testing.T) {
+	assert.Equal(t, `"say \"hi\" \\o/"`, appleScriptQuote(`say "hi" \o/`))
+}
@@ -1,41 +1,467 @@
 package main
 
 import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"time"
+
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 // NewCommand creates a new httpcheck command.
 func NewCommand() *cobra.Command {
 	opts := NewDefaultOptions()
+	var (
+		network     string
+		raw         string
+		dataBinary  string
+		ignoreStdin bool
+		preflight   string
+		ignoreNetrc bool
+		awsSigV4    string
+		hmacSigner  = NewHMACSigner()
+		oauth2      = NewOAuth2Config()
+		sessionName string
+		sessionRO   string
+		profile     string
+		proxy       string
+		fromCurl    string
+		printAs     string
+		harOut      string
+		otelURL     string
+		otelProto   string
+		otelService string
+		otelHeaders []string
+		pushURLs    []string
+		pushTags    []string
+		targets     string
+		targetsFmt  string
+		concurrency int
+		details     bool
+		resultsOut  string
+		resultsFmt  string
+		watch       time.Duration
+		detector    ChangeDetector
+		notifyCmd   string
+		notifyHook  string
+		notifyDesk  bool
+	)
+
+	// setup applies the config file and the flags shared with the
+	// subcommands to opts, and returns the config.
+	setup := func(cmd *cobra.Command) (ConfigValues, error) {
+		logrus.SetLevel(logrus.FatalLevel)
+
+		wd, err := os.Getwd()
+		if err != nil {
+			return nil, err
+		}
+		configs, err := LoadConfigs(wd)
+		if err != nil {
+			return nil, err
+		}
+		config, err := resolveConfig(configs, profile)
+		if err != nil {
+			return nil, err
+		}
+		all := pflag.NewFlagSet("all", pflag.ContinueOnError)
+		all.AddFlagSet(cmd.Root().Flags())
+		all.AddFlagSet(cmd.Root().PersistentFlags())
+		if err := config.ApplyFlags(cmd.Flags(), all); err != nil {
+			return nil, err
+		}
+		if proxy != "" {
+			if opts.Proxy, err = url.Parse(proxy); err != nil {
+				return nil, fmt.Errorf("invalid proxy '%s': %w", proxy, err)
+			}
+		}
+		if network != "" {
+			if err := opts.Network.ApplyPreset(network, cmd.Flags().Changed); err != nil {
+				return nil, err
+			}
+		}
+		if harOut != "" {
+			if opts.HAR, err = NewHARRecorder(harOut); err != nil {
+				return nil, err
+			}
+		}
+		if len(pushURLs) > 0 {
+			if opts.Push, err = NewPusher(pushURLs, pushTags); err != nil {
+				return nil, err
+			}
+		} else if len(pushTags) > 0 {
+			return nil, fmt.Errorf("cannot use --push-tag without --push")
+		}
+		if otelURL != "" {
+			if opts.OTel, err = NewOTelExporter(otelURL, otelProto, otelService, otelHeaders); err != nil {
+				return nil, err
+			}
+		}
+		opts.Warn = func(err error) {
+			fmt.Fprintf(cmd.ErrOrStderr(), "warning: %v\n", err)
+		}
+
+		return config, nil
+	}
+
+	// authenticate reads the credentials of o from ~/.netrc, unless it has
+	// some, and validates them.
+	authenticate := func(o *Options) error {
+		if o.Auth == "" && !ignoreNetrc && o.Header.Get(authorizationHeader) == "" {
+			u, err := url.Parse(o.URL)
+			if err != nil {
+				return err
+			}
+			if o.Auth, err = netrcAuth(u.Hostname()); err != nil {
+				return err
+			}
+		}
+
+		return validateAuth(o)
+	}
+
+	// writeResults writes the results of the targets to --results-out, or
+	// to stdout with "-".
+	writeResults := func(stdout io.Writer, entries []ReportEntry) error {
+		w := stdout
+		if resultsOut != "-" {
+			f, err := os.Create(resultsOut)
+			if err != nil {
+				return err
+			}
+			defer close(f)
+			w = f
+		}
+
+		return WriteResults(w, entries, resultsFmt, concurrency)
+	}
+
+	// runTargets traces the targets of the --targets file, each with the
+	// request items of args, and prints a report sorted by total time.
+	runTargets := func(cmd *cobra.Command, config ConfigValues, args []string) error {
+		r := cmd.InOrStdin()
+		if targets != "-" {
+			f, err := os.Open(targets)
+			if err != nil {
+				return err
+			}
+			defer close(f)
+			r = f
+		}
+		list, err := ReadTargetsFormat(r, targetsFmt)
+		if err != nil {
+			return fmt.Errorf("%s: %w", targets, err)
+		}
+
+		entries := RunTargets(cmd.Context(), list, opts, concurrency, func(o *Options, t Target) error {
+			if err := t.apply(o, args); err != nil {
+				return err
+			}
+			if err := config.ApplyHeaders(o.Header); err != nil {
+				return err
+			}
+			return authenticate(o)
+		})
+		if resultsOut != "" {
+			if err := writeResults(cmd.OutOrStdout(), entries); err != nil {
+				return err
+			}
+		}
+		sortByTotal(entries)
+
+		out := cmd.OutOrStdout()
+		if resultsOut == "-" {
+			// stdout only has the results, for other tools.
+			out = cmd.ErrOrStderr()
+		}
+		printOpts := []PrintOption{WithOut(out), WithShowBody(opts.ShowBody), WithMaxBodySize(opts.maxBodySize)}
+		if !isTerminal(out) {
+			printOpts = append(printOpts, WithNoColor())
+		}
+		if err := PrintReport(entries, printOpts...); err != nil {
+			return err
+		}
+		if details {
+			if err := PrintDetails(entries, printOpts...); err != nil {
+				return err
+			}
+		}
+		failed := 0
+		for _, e := range entries {
+			if e.Err != nil {
+				failed++
+			}
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d of %d requests failed", failed, len(entries))
+		}
+
+		return nil
+	}
 
 	cmd := &cobra.Command{
 		Use:   `httpcheck [METHOD] URL [REQUEST ITEM...]`,
 		Short: "Measuring HTTP performance",
 		Example: `httpcheck www.example.com
-httpcheck POST www.example.com colors:='["red", "green", "blue"]'`,
+httpcheck POST www.example.com colors:='["red", "green", "blue"]'
+httpcheck OPTIONS www.example.com
+httpcheck --from-curl "curl -X POST -H 'Content-Type: application/json' -d '{\"id\": 1}' https://www.example.com"
+httpcheck --print-as curl POST www.example.com id:=1
+httpcheck --profile staging api.example.com/health
+httpcheck --session=login POST www.example.com/login user=john password=secret
+httpcheck --oauth2 https://auth.example.com/token --oauth2-client-id app --oauth2-client-secret secret api.example.com
+httpcheck --aws-sigv4 execute-api:eu-west-1 abc123.execute-api.eu-west-1.amazonaws.com/prod/items
+httpcheck -a user:password -A digest www.example.com
+httpcheck --preflight https://app.example.com PUT api.example.com X-Token:1
+httpcheck --form POST www.example.com name=john avatar@~/avatar.png
+echo '[1, 2, 3]' | httpcheck POST www.example.com
+httpcheck POST www.example.com --data-binary @payload.xml --content-type application/xml
+httpcheck --unix-socket /var/run/docker.sock localhost/info
+httpcheck --network 3g www.example.com
+httpcheck --har-out trace.har www.example.com
+httpcheck --watch 2s api.example.com/health
+httpcheck --targets health.txt --concurrency 20
+httpcheck --targets targets.txt --targets-format vegeta --results-out - | vegeta report`,
 		SilenceUsage: true,
-		Args:         cobra.MinimumNArgs(1),
+		Args: func(cmd *cobra.Command, args []string) error {
+			if cmd.Flags().Changed("from-curl") || cmd.Flags().Changed("targets") {
+				// the arguments are request items added to the curl request,
+				// or to each target.
+				return nil
+			}
+			return cobra.MinimumNArgs(1)(cmd, args)
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			logrus.SetLevel(logrus.FatalLevel)
+			config, err := setup(cmd)
+			if err != nil {
+				return err
+			}
+
+			switch {
+			case cmd.Flags().Changed("raw"):
+				opts.RawBody = []byte(raw)
+			case cmd.Flags().Changed("data-binary"):
+				b, err := readRawBody(dataBinary, cmd.InOrStdin())
+				if err != nil {
+					return err
+				}
+				opts.RawBody = b
+			case !ignoreStdin && fromCurl == "" && targets != "-" && hasPipedInput(cmd.InOrStdin()):
+				b, err := io.ReadAll(cmd.InOrStdin())
+				if err != nil {
+					return err
+				}
+				if len(b) > 0 {
+					opts.RawBody = b
+				}
+			}
 
-			if err := ParseArgs(args, opts); err != nil {
+			if awsSigV4 != "" {
+				credentials, err := LoadAWSCredentials()
+				if err != nil {
+					return err
+				}
+				signer, err := NewSigV4Signer(awsSigV4, credentials)
+				if err != nil {
+					return err
+				}
+				opts.Signers = append(opts.Signers, signer)
+			}
+			if hmacSigner.Key != "" {
+				opts.Signers = append(opts.Signers, hmacSigner)
+			}
+			if oauth2.TokenURL != "" {
+				opts.OAuth2 = oauth2
+			}
+			opts.explicitMethod = cmd.Flags().Changed("method")
+
+			if watch > 0 && (targets != "" || printAs != "" || preflight != "") {
+				return fmt.Errorf("cannot use --watch with --targets, --print-as or --preflight")
+			}
+			changeFlags := len(detector.Headers) > 0 || detector.LatencyThreshold > 0 || notifyCmd != "" || notifyHook != "" || notifyDesk
+			if watch == 0 && changeFlags {
+				return fmt.Errorf("cannot use --change-header, --change-latency or --notify-* without --watch")
+			}
+			if targets == "" && resultsOut != "" {
+				return fmt.Errorf("cannot use --results-out without --targets")
+			}
+			if targets != "" {
+				if fromCurl != "" || printAs != "" || preflight != "" || sessionName+sessionRO != "" {
+					return fmt.Errorf("cannot use --targets with --from-curl, --print-as, --preflight or a session")
+				}
+				if !slices.Contains(resultFormats, resultsFmt) {
+					return fmt.Errorf("unknown results format '%s', must be one of: %s", resultsFmt, strings.Join(resultFormats, ", "))
+				}
+				return runTargets(cmd, config, args)
+			}
+
+			if fromCurl != "" {
+				if err := ParseCurl(fromCurl, opts); err != nil {
+					return err
+				}
+				if err := ParseItems(args, opts); err != nil {
+					return err
+				}
+			} else {
+				if err := ParseArgs(args, opts); err != nil {
+					return err
+				}
+			}
+			if err := config.ApplyHeaders(opts.Header); err != nil {
+				return err
+			}
+			var session *Session
+			if name := sessionName + sessionRO; name != "" {
+				if sessionName != "" && sessionRO != "" {
+					return fmt.Errorf("cannot use --session with --session-read-only")
+				}
+				s, err := LoadSession(name, opts.URL)
+				if err != nil {
+					return err
+				}
+				if err := s.Apply(opts); err != nil {
+					return err
+				}
+				session = s
+			}
+			if err := authenticate(opts); err != nil {
 				return err
 			}
 
+			if printAs != "" {
+				s, err := ExportRequest(cmd.Context(), opts, printAs)
+				if err != nil {
+					return err
+				}
+				_, err = fmt.Fprint(cmd.OutOrStdout(), s)
+				return err
+			}
+			if preflight != "" {
+				c, err := NewCORSRequest(opts, preflight)
+				if err != nil {
+					return err
+				}
+				r, err := Trace(cmd.Context(), c.Options(opts))
+				if err != nil {
+					return err
+				}
+
+				return PrintResult(r, WithShowBody(opts.ShowBody), WithMaxBodySize(opts.maxBodySize), WithCORS(EvaluatePreflight(c, r)))
+			}
+
+			if watch > 0 {
+				ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+				defer stop()
+				config := WatchConfig{Interval: watch, Detector: &detector}
+				if notifyCmd != "" {
+					config.Notifiers = append(config.Notifiers, CommandNotifier{Command: notifyCmd})
+				}
+				if notifyHook != "" {
+					config.Notifiers = append(config.Notifiers, WebhookNotifier{URL: notifyHook})
+				}
+				if notifyDesk {
+					config.Notifiers = append(config.Notifiers, DesktopNotifier{})
+				}
+				return RunWatch(ctx, opts, config, cmd.OutOrStdout(), WithShowBody(opts.ShowBody), WithMaxBodySize(opts.maxBodySize))
+			}
+
 			r, err := Trace(cmd.Context(), opts)
 			if err != nil {
 				return err
 			}
+			if session != nil && sessionRO == "" {
+				session.Update(opts)
+				if cmd.Flags().Changed("auth") {
+					// credentials from ~/.netrc stay there.
+					session.Auth = &SessionAuth{Type: opts.AuthType, Credential: opts.Auth}
+				}
+				if err := session.Save(); err != nil {
+					return err
+				}
+			}
 
 			return PrintResult(r, WithShowBody(opts.ShowBody), WithMaxBodySize(opts.maxBodySize))
 		},
 	}
 
 	flags := cmd.Flags()
-	flags.BoolVarP(&opts.ShowBody, "body", "b", false, "print response body")
-	flags.BoolVarP(&opts.FollowRedirect, "follow", "F", false, "follow redirects")
+	flags.StringVarP(&opts.Method, "method", "m", opts.Method, "use `method` for the request, even if it is not uppercase")
+	flags.StringVar(&fromCurl, "from-curl", "", "trace the request of a curl `command`, e.g. from \"Copy as cURL\"; arguments are added as request items")
+	flags.StringVar(&targets, "targets", "", "trace each line of `file` (or - for stdin), like \"GET example.com X-Token:1\", and print a report sorted by total time; arguments are added as request items")
+	flags.IntVar(&concurrency, "concurrency", 8, "number of --targets traced at the same time")
+	flags.BoolVar(&details, "details", false, "print the result of each target after the --targets report")
+	flags.StringVar(&targetsFmt, "targets-format", "lines", "format of the --targets file ("+strings.Join(targetFormats, ", ")+")")
+	flags.StringVar(&resultsOut, "results-out", "", "write the result of each target to `file` (or - for stdout), in the --results-format")
+	flags.StringVar(&resultsFmt, "results-format", "vegeta", "format of --results-out: vegeta JSON lines or a JMeter JTL CSV ("+strings.Join(resultFormats, ", ")+")")
+	flags.DurationVar(&watch, "watch", 0, "trace the request every `interval` and redraw a dashboard with its history, until interrupted")
+	flags.StringSliceVar(&detector.Headers, "change-header", nil, "in --watch, also report changes of the response header `name`")
+	flags.DurationVar(&detector.LatencyThreshold, "change-latency", 0, "in --watch, report a change when the total time moves by more than `duration`")
+	flags.StringVar(&notifyCmd, "notify-command", "", "in --watch, run the shell `command` on changes, with the changes as JSON input")
+	flags.StringVar(&notifyHook, "notify-webhook", "", "in --watch, POST the changes as JSON to `url`")
+	flags.BoolVar(&notifyDesk, "notify-desktop", false, "in --watch, show a desktop notification on changes")
+	flags.StringVar(&printAs, "print-as", "", "print the request as a runnable snippet ("+strings.Join(exportFormats, ", ")+") instead of sending it")
+	flags.StringVar(&preflight, "preflight", "", "send the CORS preflight of the request as a browser at `origin` would, and summarize the decision")
+	flags.StringVarP(&opts.Auth, "auth", "a", "", "authenticate with `user:password`, or a token with --auth-type bearer")
+	flags.StringVarP(&opts.AuthType, "auth-type", "A", opts.AuthType, "authentication scheme ("+strings.Join(authTypes, ", ")+")")
+	flags.BoolVar(&ignoreNetrc, "ignore-netrc", false, "do not read credentials from ~/.netrc")
+	flags.StringVar(&oauth2.TokenURL, "oauth2", "", "get a bearer token from `token-url` with the OAuth2 client credentials grant")
+	flags.StringVar(&oauth2.ClientID, "oauth2-client-id", "", "OAuth2 client ID")
+	flags.StringVar(&oauth2.ClientSecret, "oauth2-client-secret", "", "OAuth2 client secret")
+	flags.StringSliceVar(&oauth2.Scopes, "oauth2-scope", nil, "OAuth2 scopes to request")
+	flags.BoolVar(&oauth2.CredentialsInBody, "oauth2-credentials-in-body", false, "send the OAuth2 client credentials as form fields")
+	flags.BoolVar(&oauth2.NoCache, "oauth2-no-cache", false, "always request a new OAuth2 token")
+	flags.StringVar(&awsSigV4, "aws-sigv4", "", "sign the request with AWS Signature Version 4 for `service:region`")
+	flags.StringVar(&hmacSigner.Key, "hmac-key", "", "sign the request with an HMAC using `key`")
+	flags.StringVar(&hmacSigner.Algorithm, "hmac-algorithm", hmacSigner.Algorithm, "hmac hash function (sha1, sha256, sha512)")
+	flags.StringVar(&hmacSigner.Canonical, "hmac-canonical", hmacSigner.Canonical, "`template` of the string signed with --hmac-key")
+	flags.StringVar(&hmacSigner.Header, "hmac-header", hmacSigner.Header, "header carrying the hmac signature")
+	flags.StringVar(&hmacSigner.Format, "hmac-format", hmacSigner.Format, "`template` of the hmac header value")
+	flags.StringVar(&hmacSigner.Encoding, "hmac-encoding", hmacSigner.Encoding, "hmac signature encoding (hex, base64)")
+	flags.StringVar(&hmacSigner.TimestampHeader, "hmac-timestamp-header", "", "add the signing time as a Unix timestamp in `header`")
+	flags.StringVar(&sessionName, "session", "", "reuse and update the cookies, auth and headers of the session `name` (or path)")
+	flags.StringVar(&sessionRO, "session-read-only", "", "reuse the session `name` (or path) without updating it")
 	flags.BoolVarP(&opts.IsForm, "form", "f", false, "serialize data items as form fields")
+	flags.BoolVar(&opts.IsMultipart, "multipart", false, "serialize data items and files as multipart/form-data")
+	flags.StringVar(&raw, "raw", "", "send `data` as the request body")
+	flags.StringVar(&dataBinary, "data-binary", "", "send the content of `@file` (or @- for stdin) as the request body")
+	flags.BoolVar(&ignoreStdin, "ignore-stdin", false, "do not read the request body from stdin")
+	flags.StringVar(&opts.ContentType, "content-type", "", "set the Content-Type of the request body")
+
+	// the flags shared with the subcommands.
+	pflags := cmd.PersistentFlags()
+	pflags.BoolVarP(&opts.ShowBody, "body", "b", false, "print response body")
+	pflags.StringVar(&profile, "profile", "", "use the flags of the config file profile `name`")
+	pflags.DurationVar(&opts.timeout, "timeout", opts.timeout, "give up on the request after this duration")
+	pflags.BoolVarP(&opts.Insecure, "insecure", "k", false, "skip the TLS certificate verification")
+	pflags.StringVar(&proxy, "proxy", "", "send the request through the proxy at `url`")
+	pflags.BoolVarP(&opts.FollowRedirect, "follow", "F", false, "follow redirects")
+	pflags.StringVar(&network, "network", "", "emulate a network preset ("+strings.Join(NetworkPresets(), ", ")+")")
+	pflags.DurationVar(&opts.Network.Latency, "latency", 0, "add latency to every round trip")
+	pflags.DurationVar(&opts.Network.Jitter, "jitter", 0, "add up to this much random latency to every round trip")
+	pflags.DurationVar(&opts.Network.PacketDelay, "packet-delay", 0, "delay every packet read or written")
+	pflags.Var(&opts.Network.Download, "download", "limit the download bandwidth, e.g. 1.6mbps")
+	pflags.Var(&opts.Network.Upload, "upload", "limit the upload bandwidth, e.g. 750kbps")
+	pflags.StringVar(&opts.UnixSocket, "unix-socket", "", "connect through the Unix domain socket at `path`")
+	pflags.StringVar(&harOut, "har-out", "", "write the traced requests and their timings to the HAR `file`")
+	pflags.StringVar(&otelURL, "otel-endpoint", "", "export each traced request as an OpenTelemetry trace to the OTLP collector at `url`")
+	pflags.StringVar(&otelProto, "otel-protocol", "http/protobuf", "OTLP protocol of --otel-endpoint ("+strings.Join(otelProtocols, ", ")+")")
+	pflags.StringArrayVar(&otelHeaders, "otel-header", nil, "send the header `name=value` with the exported traces")
+	pflags.StringVar(&otelService, "otel-service", "httpcheck", "service.name of the exported traces")
+	pflags.StringArrayVar(&pushURLs, "push", nil, "push the metrics of each traced request to `url` (statsd://, influx://, pushgateway://)")
+	pflags.StringArrayVar(&pushTags, "push-tag", nil, "tag the pushed metrics with `key=value`, like region=eu; target defaults to the URL")
+
+	cmd.AddCommand(newRunCommand(opts, setup))
+	cmd.AddCommand(newPostmanCommand(opts, setup))
+	cmd.AddCommand(newReplayCommand(opts, setup))
+	cmd.AddCommand(newScenarioCommand(opts, setup))
+	cmd.AddCommand(newServeCommand(opts, setup))
 
 	return cmd
 }
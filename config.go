@@ -0,0 +1,205 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+// projectConfigName is the name of the project-local config file, looked up
+// in the working directory and its parents.
+const projectConfigName = ".httpcheck.yaml"
+
+// Config is the content of a config file. A config file sets defaults for
+// the command line flags, and named profiles of flags selected with
+// --profile.
+type Config struct {
+	Defaults ConfigValues            `yaml:"defaults"`
+	Profiles map[string]ConfigValues `yaml:"profiles"`
+}
+
+// ConfigValues maps flag names, without the leading dashes, to their value.
+// The headers key sets request headers.
+type ConfigValues map[string]any
+
+// configPaths returns the config files that exist, the user config first and
+// the project-local one, in dir or its closest parent, last.
+func configPaths(dir string) ([]string, error) {
+	var paths []string
+	if configDir, err := os.UserConfigDir(); err == nil {
+		path := filepath.Join(configDir, "httpcheck", "config.yaml")
+		if _, err := os.Stat(path); err == nil {
+			paths = append(paths, path)
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+
+	for {
+		path := filepath.Join(dir, projectConfigName)
+		_, err := os.Stat(path)
+		if err == nil {
+			return append(paths, path), nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return paths, nil
+		}
+		dir = parent
+	}
+}
+
+// LoadConfigs reads the config files that apply to the working directory dir.
+func LoadConfigs(dir string) ([]*Config, error) {
+	paths, err := configPaths(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var configs []*Config
+	for _, path := range paths {
+		b, err := os.ReadFile(filepath.Clean(path))
+		if err != nil {
+			return nil, err
+		}
+		c := &Config{}
+		if err := yaml.Unmarshal(b, c); err != nil {
+			return nil, fmt.Errorf("invalid config file '%s': %w", path, err)
+		}
+		configs = append(configs, c)
+	}
+
+	return configs, nil
+}
+
+// resolveConfig merges the defaults and the profile of every config, later
+// configs and profiles overriding earlier ones.
+func resolveConfig(configs []*Config, profile string) (ConfigValues, error) {
+	values := ConfigValues{}
+	found := profile == ""
+	for _, c := range configs {
+		values.merge(c.Defaults)
+		if p, ok := c.Profiles[profile]; ok && profile != "" {
+			values.merge(p)
+			found = true
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("unknown profile '%s'", profile)
+	}
+
+	return values, nil
+}
+
+func (v ConfigValues) merge(other ConfigValues) {
+	for k, value := range other {
+		if k == "headers" {
+			headers, _ := configMap(v[k])
+			merged := map[string]any{}
+			for name, value := range headers {
+				merged[name] = value
+			}
+			if other, ok := configMap(value); ok {
+				for name, value := range other {
+					merged[name] = value
+				}
+			}
+			value = merged
+		}
+		v[k] = value
+	}
+}
+
+// ApplyFlags sets the flags that were not given on the command line. Keys
+// must be flags of all, the flags of the root command, and are ignored by
+// the subcommands that do not have them.
+func (v ConfigValues) ApplyFlags(flags, all *pflag.FlagSet) error {
+	for name, value := range v {
+		if name == "headers" {
+			continue
+		}
+		if all.Lookup(name) == nil || name == "profile" {
+			return fmt.Errorf("unknown config key '%s'", name)
+		}
+		flag := flags.Lookup(name)
+		if flag == nil || flag.Changed {
+			continue
+		}
+		values, ok := value.([]any)
+		if !ok {
+			values = []any{value}
+		}
+		for _, value := range values {
+			s, err := configString(value)
+			if err != nil {
+				return fmt.Errorf("invalid config value for '%s': %w", name, err)
+			}
+			if err := flags.Set(name, s); err != nil {
+				return fmt.Errorf("invalid config value for '%s': %w", name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// ApplyHeaders adds the headers that are not set, or removed, by request
+// items.
+func (v ConfigValues) ApplyHeaders(header http.Header) error {
+	headers, ok := configMap(v["headers"])
+	if v["headers"] != nil && !ok {
+		return fmt.Errorf("invalid config value for 'headers': must be a mapping")
+	}
+	for name, value := range headers {
+		if _, ok := header[http.CanonicalHeaderKey(name)]; ok {
+			continue
+		}
+		s, err := configString(value)
+		if err != nil {
+			return fmt.Errorf("invalid config value for header '%s': %w", name, err)
+		}
+		header.Set(name, s)
+	}
+
+	return nil
+}
+
+// configMap returns value as a map. Mappings nested in a ConfigValues decode
+// as ConfigValues.
+func configMap(value any) (map[string]any, bool) {
+	switch v := value.(type) {
+	case ConfigValues:
+		return v, true
+	case map[string]any:
+		return v, true
+	}
+
+	return nil, false
+}
+
+func configString(value any) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case nil:
+		return "", nil
+	}
+
+	return "", fmt.Errorf("%v is not a scalar", strings.TrimSpace(fmt.Sprint(value)))
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfigs(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", home)
+	t.Setenv("HOME", home)
+	require.NoError(t, os.MkdirAll(filepath.Join(home, "httpcheck"), 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(home, "httpcheck", "config.yaml"), []byte(`
+defaults:
+  timeout: 5s
+  follow: true
+  headers:
+    X-Team: core
+    X-Env: dev
+profiles:
+  staging:
+    auth: user:pass
+    headers:
+      X-Env: staging
+`), 0o600))
+	project := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(project, ".httpcheck.yaml"), []byte(`
+defaults:
+  timeout: 2s
+profiles:
+  staging:
+    oauth2-scope: [read, write]
+`), 0o600))
+	dir := filepath.Join(project, "sub", "dir")
+	require.NoError(t, os.MkdirAll(dir, 0o700))
+
+	configs, err := LoadConfigs(dir)
+	require.NoError(t, err)
+	require.Len(t, configs, 2)
+
+	values, err := resolveConfig(configs, "staging")
+	require.NoError(t, err)
+	assert.Equal(t, ConfigValues{
+		"timeout":      "2s",
+		"follow":       true,
+		"auth":         "user:pass",
+		"oauth2-scope": []any{"read", "write"},
+		"headers":      map[string]any{"X-Team": "core", "X-Env": "staging"},
+	}, values)
+
+	_, err = resolveConfig(configs, "production")
+	assert.EqualError(t, err, "unknown profile 'production'")
+}
+
+func TestConfigValues_Apply(t *testing.T) {
+	opts := NewDefaultOptions()
+	var scopes []string
+	cmd := &cobra.Command{}
+	cmd.Flags().DurationVar(&opts.timeout, "timeout", opts.timeout, "")
+	cmd.Flags().BoolVarP(&opts.FollowRedirect, "follow", "F", false, "")
+	cmd.Flags().StringVar(&opts.Auth, "auth", "", "")
+	cmd.Flags().StringSliceVar(&scopes, "oauth2-scope", nil, "")
+	require.NoError(t, cmd.Flags().Parse([]string{"--auth", "flag:pass"}))
+	opts.Header.Set("X-Env", "item")
+	opts.Header["Accept"] = nil
+
+	values := ConfigValues{
+		"timeout":      "2s",
+		"follow":       true,
+		"auth":         "user:pass",
+		"oauth2-scope": []any{"read", "write"},
+		"headers":      map[string]any{"X-Team": "core", "X-Env": "staging", "Accept": "*/*"},
+	}
+	require.NoError(t, values.ApplyFlags(cmd.Flags(), cmd.Flags()))
+	require.NoError(t, values.ApplyHeaders(opts.Header))
+
+	assert.Equal(t, 2*time.Second, opts.timeout)
+	assert.True(t, opts.FollowRedirect)
+	assert.Equal(t, "flag:pass", opts.Auth)
+	assert.Equal(t, []string{"read", "write"}, scopes)
+	assert.Equal(t, "core", opts.Header.Get("X-Team"))
+	assert.Equal(t, "item", opts.Header.Get("X-Env"))
+	assert.Nil(t, opts.Header["Accept"])
+
+	cmd = &cobra.Command{}
+	cmd.Flags().DurationVar(&opts.timeout, "timeout", opts.timeout, "")
+	err := ConfigValues{"verbose": true}.ApplyFlags(cmd.Flags(), cmd.Flags())
+	assert.EqualError(t, err, "unknown config key 'verbose'")
+	err = ConfigValues{"timeout": "soon"}.ApplyFlags(cmd.Flags(), cmd.Flags())
+	assert.ErrorContains(t, err, "invalid config value for 'timeout'")
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+var (
+	// corsSafelistedMethods never need to be allowed by a preflight.
+	corsSafelistedMethods = []string{
+		http.MethodGet,
+		http.MethodHead,
+		http.MethodPost,
+	}
+
+	// corsSafelistedHeaders are never listed in Access-Control-Request-Headers.
+	corsSafelistedHeaders = []string{
+		"accept",
+		"accept-language",
+		"content-language",
+	}
+
+	// corsSafelistedContentTypes are the Content-Type values that do not need
+	// to be allowed by a preflight.
+	corsSafelistedContentTypes = []string{
+		"application/x-www-form-urlencoded",
+		"multipart/form-data",
+		"text/plain",
+	}
+)
+
+// CORSRequest is the cross-origin request a preflight asks permission for.
+type CORSRequest struct {
+	Origin  string
+	Method  string
+	Headers []string
+}
+
+// NewCORSRequest returns the cross-origin request a browser running at
+// origin would make to send the request described by opts.
+func NewCORSRequest(opts *Options, origin string) (*CORSRequest, error) {
+	req, err := newRequest(context.Background(), opts)
+	if err != nil {
+		return nil, err
+	}
+	if req.Body != nil {
+		defer close(req.Body)
+	}
+
+	c := &CORSRequest{
+		Origin: origin,
+		Method: opts.Method,
+	}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if len(values) == 0 || slices.Contains(corsSafelistedHeaders, name) {
+			continue
+		}
+		if name == "content-type" {
+			mediaType, _, err := mime.ParseMediaType(values[0])
+			if err == nil && slices.Contains(corsSafelistedContentTypes, mediaType) {
+				continue
+			}
+		}
+		c.Headers = append(c.Headers, name)
+	}
+	slices.Sort(c.Headers)
+
+	return c, nil
+}
+
+// Options returns the options of the preflight request for c, sent to the
+// same target as opts. Browsers never send credentials with a preflight, so
+// it has none of the authentication, signatures or cookies of opts, and
+// only the headers of the preflight.
+func (c *CORSRequest) Options(opts *Options) *Options {
+	p := *opts
+	p.Method = http.MethodOptions
+	p.Header = http.Header{
+		"Origin":                        {c.Origin},
+		"Access-Control-Request-Method": {c.Method},
+		acceptHeader:                    {"*/*"},
+		contentTypeHeader:               nil,
+	}
+	if len(c.Headers) > 0 {
+		p.Header.Set("Access-Control-Request-Headers", strings.Join(c.Headers, ","))
+	}
+	p.Data = map[string]any{}
+	p.FormData = url.Values{}
+	p.Files = nil
+	p.RawBody = nil
+	p.ContentType = ""
+	p.IsForm = false
+	p.IsMultipart = false
+	p.Auth = ""
+	p.AuthType = authTypeBasic
+	p.OAuth2 = nil
+	p.Signers = nil
+	p.Jar = nil
+
+	return &p
+}
+
+// CORSCheck is a single condition of a preflight decision.
+type CORSCheck struct {
+	Name   string
+	Detail string
+	OK     bool
+}
+
+// CORSDecision summarizes whether a preflight allows the cross-origin request.
+type CORSDecision struct {
+	Request          *CORSRequest
+	Allowed          bool
+	Checks           []CORSCheck
+	AllowCredentials bool
+	MaxAge           string
+}
+
+// EvaluatePreflight decides, like a browser would, whether the preflight
+// response r allows the cross-origin request c.
+func EvaluatePreflight(c *CORSRequest, r *Result) *CORSDecision {
+	d := &CORSDecision{
+		Request:          c,
+		AllowCredentials: r.Header("Access-Control-Allow-Credentials") == "true",
+		MaxAge:           r.Header("Access-Control-Max-Age"),
+	}
+
+	status := CORSCheck{Name: "status", Detail: r.Status, OK: strings.HasPrefix(r.Status, "2")}
+	if !status.OK {
+		status.Detail = fmt.Sprintf("%s is not a successful status", r.Status)
+	}
+
+	origin := CORSCheck{Name: "origin"}
+	switch allowed := r.Header("Access-Control-Allow-Origin"); {
+	case allowed == "":
+		origin.Detail = "Access-Control-Allow-Origin is missing"
+	case allowed == "*" && d.AllowCredentials:
+		origin.Detail = "Access-Control-Allow-Origin cannot be * with credentials"
+	case allowed == "*" || allowed == c.Origin:
+		origin.OK = true
+		origin.Detail = "allowed by Access-Control-Allow-Origin: " + allowed
+	default:
+		origin.Detail = "Access-Control-Allow-Origin is " + allowed
+	}
+
+	allowedMethods := corsList(r.Header("Access-Control-Allow-Methods"))
+	method := CORSCheck{Name: "method", Detail: c.Method + " is not in Access-Control-Allow-Methods"}
+	switch {
+	case slices.Contains(corsSafelistedMethods, c.Method):
+		method.OK = true
+		method.Detail = c.Method + " is safelisted"
+	case slices.Contains(allowedMethods, c.Method) || (slices.Contains(allowedMethods, "*") && !d.AllowCredentials):
+		method.OK = true
+		method.Detail = c.Method + " is allowed"
+	}
+
+	allowedHeaders := corsList(strings.ToLower(r.Header("Access-Control-Allow-Headers")))
+	wildcard := slices.Contains(allowedHeaders, "*") && !d.AllowCredentials
+	var denied []string
+	for _, h := range c.Headers {
+		// the wildcard never covers Authorization.
+		if !slices.Contains(allowedHeaders, h) && (!wildcard || h == "authorization") {
+			denied = append(denied, h)
+		}
+	}
+	headers := CORSCheck{Name: "headers", OK: len(denied) == 0}
+	switch {
+	case len(denied) > 0:
+		headers.Detail = strings.Join(denied, ", ") + " not in Access-Control-Allow-Headers"
+	case len(c.Headers) > 0:
+		headers.Detail = strings.Join(c.Headers, ", ") + " allowed"
+	default:
+		headers.Detail = "no custom headers requested"
+	}
+
+	d.Checks = []CORSCheck{status, origin, method, headers}
+	d.Allowed = true
+	for _, check := range d.Checks {
+		d.Allowed = d.Allowed && check.OK
+	}
+
+	return d
+}
+
+func corsList(s string) []string {
+	var values []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+
+	return values
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewCORSRequest(t *testing.T) {
+	opts := NewDefaultOptions()
+	opts.Method = http.MethodPut
+	opts.URL = "https://api.example.com"
+	opts.Header.Set("X-Token", "1")
+	opts.Header.Set("Accept-Language", "en")
+
+	c, err := NewCORSRequest(opts, "https://app.example.com")
+
+	require.NoError(t, err)
+	assert.Equal(t, &CORSRequest{
+		Origin:  "https://app.example.com",
+		Method:  http.MethodPut,
+		Headers: []string{"content-type", "x-token"},
+	}, c)
+}
+
+func TestNewCORSRequest_safelistedContentType(t *testing.T) {
+	opts := NewDefaultOptions()
+	opts.Method = http.MethodPost
+	opts.URL = "https://api.example.com"
+	opts.IsForm = true
+
+	c, err := NewCORSRequest(opts, "https://app.example.com")
+
+	require.NoError(t, err)
+	assert.Empty(t, c.Headers)
+}
+
+func TestEvaluatePreflight(t *testing.T) {
+	c := &CORSRequest{
+		Origin:  "https://app.example.com",
+		Method:  http.MethodPut,
+		Headers: []string{"authorization", "x-token"},
+	}
+	cases := []struct {
+		name    string
+		result  *Result
+		allowed bool
+	}{
+		{
+			name: "allowed",
+			result: &Result{Status: "204", Headers: []Header{
+				{Name: "Access-Control-Allow-Headers", Value: "Authorization, X-Token"},
+				{Name: "Access-Control-Allow-Methods", Value: "GET, PUT"},
+				{Name: "Access-Control-Allow-Origin", Value: "https://app.example.com"},
+			}},
+			allowed: true,
+		},
+		{
+			name: "wildcard does not cover authorization",
+			result: &Result{Status: "204", Headers: []Header{
+				{Name: "Access-Control-Allow-Headers", Value: "*"},
+				{Name: "Access-Control-Allow-Methods", Value: "*"},
+				{Name: "Access-Control-Allow-Origin", Value: "*"},
+			}},
+		},
+		{
+			name: "method not allowed",
+			result: &Result{Status: "204", Headers: []Header{
+				{Name: "Access-Control-Allow-Headers", Value: "authorization, x-token"},
+				{Name: "Access-Control-Allow-Methods", Value: "GET"},
+				{Name: "Access-Control-Allow-Origin", Value: "*"},
+			}},
+		},
+		{
+			name:   "missing headers",
+			result: &Result{Status: "404"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := EvaluatePreflight(c, tc.result)
+			assert.Equal(t, tc.allowed, d.Allowed)
+		})
+	}
+}
+
+func TestTrace_preflight(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, http.MethodOptions, req.Method)
+		assert.Equal(t, "https://app.example.com", req.Header.Get("Origin"))
+		assert.Equal(t, "PATCH", req.Header.Get("Access-Control-Request-Method"))
+		// the request announces its credentials, without sending them.
+		assert.Equal(t, "authorization,content-type,x-amz-date,x-token", req.Header.Get("Access-Control-Request-Headers"))
+		assert.Empty(t, req.Header.Get("Content-Type"))
+		assert.Zero(t, req.ContentLength)
+		assert.Empty(t, req.Header.Get("Authorization"))
+		assert.Empty(t, req.Header.Get("Cookie"))
+		assert.Empty(t, req.Header.Get("X-Amz-Date"))
+
+		rw.Header().Set("Access-Control-Allow-Origin", "https://app.example.com")
+		rw.Header().Set("Access-Control-Allow-Methods", "PATCH")
+		rw.Header().Set("Access-Control-Allow-Headers", "authorization, content-type, x-amz-date, x-token")
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer svr.Close()
+
+	opts := NewDefaultOptions()
+	opts.Method = http.MethodPatch
+	opts.URL = svr.URL
+	opts.Header.Set("X-Token", "1")
+	opts.Data["k"] = "v"
+	opts.Auth = "john:secret"
+	signer, err := NewSigV4Signer("execute-api:eu-west-1", AWSCredentials{AccessKeyID: "id", SecretAccessKey: "secret"})
+	require.NoError(t, err)
+	opts.Signers = []Signer{signer}
+	u, err := url.Parse(svr.URL)
+	require.NoError(t, err)
+	opts.Jar, err = cookiejar.New(nil)
+	require.NoError(t, err)
+	opts.Jar.SetCookies(u, []*http.Cookie{{Name: "sid", Value: "1"}})
+	c, err := NewCORSRequest(opts, "https://app.example.com")
+	require.NoError(t, err)
+	r, err := Trace(context.Background(), c.Options(opts))
+	require.NoError(t, err)
+
+	assert.True(t, EvaluatePreflight(c, r).Allowed)
+}
@@ -0,0 +1,492 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// curlOptions maps the long names of the supported curl options to whether
+// they take a value.
+var curlOptions = map[string]bool{
+	"request":        true,
+	"header":         true,
+	"data":           true,
+	"data-ascii":     true,
+	"data-raw":       true,
+	"data-binary":    true,
+	"data-urlencode": true,
+	"form":           true,
+	"form-string":    true,
+	"user":           true,
+	"oauth2-bearer":  true,
+	"user-agent":     true,
+	"referer":        true,
+	"cookie":         true,
+	"max-time":       true,
+	"proxy":          true,
+	"url":            true,
+	"digest":         false,
+	"basic":          false,
+	"insecure":       false,
+	"location":       false,
+	"get":            false,
+	"head":           false,
+
+	// options without an effect on the request.
+	"compressed":      false,
+	"silent":          false,
+	"show-error":      false,
+	"verbose":         false,
+	"include":         false,
+	"fail":            false,
+	"globoff":         false,
+	"no-buffer":       false,
+	"http1.1":         false,
+	"http2":           false,
+	"output":          true,
+	"write-out":       true,
+	"connect-timeout": true,
+}
+
+// curlShortOptions maps the short curl options to their long name.
+var curlShortOptions = map[byte]string{
+	'X': "request",
+	'H': "header",
+	'd': "data",
+	'F': "form",
+	'u': "user",
+	'A': "user-agent",
+	'e': "referer",
+	'b': "cookie",
+	'm': "max-time",
+	'x': "proxy",
+	'k': "insecure",
+	'L': "location",
+	'G': "get",
+	'I': "head",
+	's': "silent",
+	'S': "show-error",
+	'v': "verbose",
+	'i': "include",
+	'f': "fail",
+	'g': "globoff",
+	'N': "no-buffer",
+	'o': "output",
+	'w': "write-out",
+}
+
+type curlOption struct {
+	name  string
+	value string
+}
+
+// parseCurlArgs splits the words of a curl command into its options and
+// URLs.
+func parseCurlArgs(words []string) ([]curlOption, []string, error) {
+	var (
+		options []curlOption
+		urls    []string
+	)
+	for i := 0; i < len(words); i++ {
+		word := words[i]
+		var names []string
+		value, hasValue := "", false
+		switch {
+		case word == "--":
+			urls = append(urls, words[i+1:]...)
+			return options, urls, nil
+		case strings.HasPrefix(word, "--"):
+			names = []string{strings.TrimPrefix(word, "--")}
+		case strings.HasPrefix(word, "-") && len(word) > 1:
+			// short options can be grouped, the last one taking the rest of
+			// the word as its value: -sSL, -XPOST.
+			for j := 1; j < len(word); j++ {
+				name, ok := curlShortOptions[word[j]]
+				if !ok {
+					return nil, nil, fmt.Errorf("unsupported curl option '-%c'", word[j])
+				}
+				names = append(names, name)
+				if curlOptions[name] && j+1 < len(word) {
+					value, hasValue = word[j+1:], true
+					break
+				}
+			}
+		default:
+			urls = append(urls, word)
+			continue
+		}
+
+		for j, name := range names {
+			takesValue, ok := curlOptions[name]
+			if !ok {
+				return nil, nil, fmt.Errorf("unsupported curl option '--%s'", name)
+			}
+			if !takesValue {
+				options = append(options, curlOption{name: name})
+				continue
+			}
+			if j < len(names)-1 {
+				return nil, nil, fmt.Errorf("curl option '--%s' needs a value", name)
+			}
+			if !hasValue {
+				if i+1 >= len(words) {
+					return nil, nil, fmt.Errorf("curl option '--%s' needs a value", name)
+				}
+				i++
+				value = words[i]
+			}
+			if name == "url" {
+				urls = append(urls, value)
+				continue
+			}
+			options = append(options, curlOption{name: name, value: value})
+		}
+	}
+
+	return options, urls, nil
+}
+
+// ParseCurl updates opts with the request of a curl command line, as copied
+// from browsers' "Copy as cURL".
+func ParseCurl(command string, opts *Options) error {
+	words, err := splitShellWords(command)
+	if err != nil {
+		return err
+	}
+	if len(words) == 0 || words[0] != "curl" {
+		return fmt.Errorf("not a curl command: '%s'", command)
+	}
+	options, urls, err := parseCurlArgs(words[1:])
+	if err != nil {
+		return err
+	}
+	if len(urls) != 1 {
+		return fmt.Errorf("curl command must have exactly one URL, got %d", len(urls))
+	}
+
+	var (
+		method string
+		data   []string
+		isGet  bool
+	)
+	for _, o := range options {
+		switch o.name {
+		case "request":
+			method = o.value
+		case "header":
+			if err := parseCurlHeader(o.value, opts); err != nil {
+				return err
+			}
+		case "data", "data-ascii", "data-binary":
+			v := o.value
+			if path, ok := strings.CutPrefix(v, "@"); ok {
+				if v, err = readCurlFile(path); err != nil {
+					return err
+				}
+				if o.name != "data-binary" {
+					v = strings.NewReplacer("\r", "", "\n", "").Replace(v)
+				}
+			}
+			data = append(data, v)
+		case "data-raw":
+			data = append(data, o.value)
+		case "data-urlencode":
+			v, err := curlURLEncode(o.value)
+			if err != nil {
+				return err
+			}
+			data = append(data, v)
+		case "form", "form-string":
+			if err := parseCurlForm(o.value, o.name == "form-string", opts); err != nil {
+				return err
+			}
+		case "user":
+			if !strings.Contains(o.value, ":") {
+				return fmt.Errorf("curl option '--user %s' has no password", o.value)
+			}
+			opts.Auth = o.value
+		case "oauth2-bearer":
+			opts.Auth = o.value
+			opts.AuthType = authTypeBearer
+		case "digest":
+			opts.AuthType = authTypeDigest
+		case "basic":
+			opts.AuthType = authTypeBasic
+		case "user-agent":
+			opts.Header.Set("User-Agent", o.value)
+		case "referer":
+			opts.Header.Set("Referer", o.value)
+		case "cookie":
+			if !strings.Contains(o.value, "=") {
+				return fmt.Errorf("curl cookie files are not supported: '%s'", o.value)
+			}
+			opts.Header.Add("Cookie", o.value)
+		case "max-time":
+			seconds, err := strconv.ParseFloat(o.value, 64)
+			if err != nil {
+				return fmt.Errorf("invalid curl --max-time '%s'", o.value)
+			}
+			opts.timeout = time.Duration(seconds * float64(time.Second))
+		case "proxy":
+			proxy := o.value
+			if !strings.Contains(proxy, "://") {
+				proxy = "http://" + proxy
+			}
+			if opts.Proxy, err = url.Parse(proxy); err != nil {
+				return fmt.Errorf("invalid curl --proxy '%s': %w", o.value, err)
+			}
+		case "insecure":
+			opts.Insecure = true
+		case "location":
+			opts.FollowRedirect = true
+		case "get":
+			isGet = true
+		case "head":
+			method = http.MethodHead
+		}
+	}
+
+	opts.URL = urls[0]
+	if !strings.Contains(opts.URL, "://") {
+		opts.URL = "http://" + opts.URL
+	}
+
+	opts.Method = http.MethodGet
+	switch {
+	case isGet && len(data) > 0:
+		q, err := url.ParseQuery(strings.Join(data, "&"))
+		if err != nil {
+			return fmt.Errorf("invalid curl --get data: %w", err)
+		}
+		for k, values := range q {
+			opts.QueryParams[k] = append(opts.QueryParams[k], values...)
+		}
+	case len(data) > 0:
+		opts.Method = http.MethodPost
+		opts.RawBody = []byte(strings.Join(data, "&"))
+		opts.ContentType = "application/x-www-form-urlencoded"
+	case opts.IsMultipart:
+		opts.Method = http.MethodPost
+	}
+	if method != "" {
+		opts.Method = method
+	}
+
+	// curl sends "Accept: */*", and a Content-Type only with a body.
+	if _, ok := opts.Header[acceptHeader]; !ok {
+		opts.Header.Set(acceptHeader, "*/*")
+	}
+	if _, ok := opts.Header[contentTypeHeader]; !ok && opts.RawBody == nil && !opts.IsMultipart {
+		opts.Header[contentTypeHeader] = nil
+	}
+
+	return nil
+}
+
+// parseCurlHeader adds a "Name: value" curl header to opts. Like curl,
+// "Name:" removes the header and "Name;" sends it empty.
+func parseCurlHeader(h string, opts *Options) error {
+	if name, ok := strings.CutSuffix(strings.TrimSpace(h), ";"); ok && !strings.Contains(name, ":") {
+		opts.Header.Add(name, "")
+		return nil
+	}
+	name, value, ok := strings.Cut(h, ":")
+	if !ok {
+		return fmt.Errorf("invalid curl header '%s'", h)
+	}
+	name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+	if value == "" {
+		opts.Header[http.CanonicalHeaderKey(name)] = nil
+		return nil
+	}
+	opts.Header.Add(name, value)
+
+	return nil
+}
+
+// parseCurlForm adds a -F field to opts: "name=value", "name=@file",
+// optionally followed by ";type=mime/type", or "name=<file" to read the
+// value from a file.
+func parseCurlForm(field string, literal bool, opts *Options) error {
+	name, value, ok := strings.Cut(field, "=")
+	if !ok {
+		return fmt.Errorf("invalid curl form field '%s'", field)
+	}
+	opts.IsMultipart = true
+
+	switch {
+	case !literal && strings.HasPrefix(value, "@"):
+		f, err := parseFormFile(name, strings.TrimPrefix(value, "@"))
+		if err != nil {
+			return err
+		}
+		opts.Files = append(opts.Files, f)
+	case !literal && strings.HasPrefix(value, "<"):
+		content, err := readCurlFile(strings.TrimPrefix(value, "<"))
+		if err != nil {
+			return err
+		}
+		opts.FormData.Add(name, content)
+	default:
+		opts.FormData.Add(name, value)
+	}
+
+	return nil
+}
+
+// curlURLEncode encodes a --data-urlencode value: "content", "=content",
+// "name=content", "@file" or "name@file".
+func curlURLEncode(v string) (string, error) {
+	if name, content, ok := strings.Cut(v, "="); ok {
+		if name == "" {
+			return url.QueryEscape(content), nil
+		}
+		return name + "=" + url.QueryEscape(content), nil
+	}
+	if name, path, ok := strings.Cut(v, "@"); ok {
+		content, err := readCurlFile(path)
+		if err != nil {
+			return "", err
+		}
+		if name == "" {
+			return url.QueryEscape(content), nil
+		}
+		return name + "=" + url.QueryEscape(content), nil
+	}
+
+	return url.QueryEscape(v), nil
+}
+
+func readCurlFile(path string) (string, error) {
+	if path == "-" {
+		return "", fmt.Errorf("curl data from stdin is not supported, use --data-binary @- instead")
+	}
+
+	return readItemFile(path)
+}
+
+// splitShellWords splits a POSIX shell command line into words, handling
+// single quotes, double quotes, $'...' strings and backslash escapes.
+func splitShellWords(s string) ([]string, error) {
+	var (
+		words  []string
+		word   strings.Builder
+		inWord bool
+	)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		case c == '\\':
+			inWord = true
+			if i+1 < len(s) {
+				i++
+				if s[i] == '\n' {
+					// a line continuation.
+					inWord = word.Len() > 0
+					continue
+				}
+				if s[i] == '\r' && i+1 < len(s) && s[i+1] == '\n' {
+					i++
+					inWord = word.Len() > 0
+					continue
+				}
+				word.WriteByte(s[i])
+			}
+		case c == '\'':
+			inWord = true
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated single quote in '%s'", s)
+			}
+			word.WriteString(s[i+1 : i+1+end])
+			i += end + 1
+		case c == '$' && i+1 < len(s) && s[i+1] == '\'':
+			inWord = true
+			n, err := ansiCString(s[i+2:], &word)
+			if err != nil {
+				return nil, err
+			}
+			i += n + 1
+		case c == '"':
+			inWord = true
+			i++
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) && strings.IndexByte("$`\"\\\n", s[i+1]) >= 0 {
+					i++
+					if s[i] == '\n' {
+						continue
+					}
+				}
+				word.WriteByte(s[i])
+			}
+			if i >= len(s) {
+				return nil, fmt.Errorf("unterminated double quote in '%s'", s)
+			}
+		default:
+			inWord = true
+			word.WriteByte(c)
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+
+	return words, nil
+}
+
+// ansiCString writes the content of a $'...' string starting at s, and
+// returns the length of the content and its closing quote.
+func ansiCString(s string, w *strings.Builder) (int, error) {
+	escapes := map[byte]byte{
+		'n': '\n', 't': '\t', 'r': '\r', '\\': '\\', '\'': '\'', '"': '"', 'a': '\a', 'b': '\b', 'e': 0x1b, 'f': '\f', 'v': '\v',
+	}
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\'':
+			return i + 1, nil
+		case s[i] == '\\' && i+1 < len(s):
+			i++
+			if c, ok := escapes[s[i]]; ok {
+				w.WriteByte(c)
+				continue
+			}
+			if s[i] == 'x' || s[i] == 'u' {
+				n := 2
+				if s[i] == 'u' {
+					n = 4
+				}
+				j := i + 1
+				for j < len(s) && j < i+1+n && strings.IndexByte("0123456789abcdefABCDEF", s[j]) >= 0 {
+					j++
+				}
+				v, err := strconv.ParseUint(s[i+1:j], 16, 32)
+				if err != nil {
+					return 0, fmt.Errorf("invalid escape '\\%s' in $'...' string", s[i:j])
+				}
+				if s[i] == 'x' {
+					w.WriteByte(byte(v))
+				} else {
+					w.WriteRune(rune(v))
+				}
+				i = j - 1
+				continue
+			}
+			w.WriteByte('\\')
+			w.WriteByte(s[i])
+		default:
+			w.WriteByte(s[i])
+		}
+	}
+
+	return 0, fmt.Errorf("unterminated $'...' string")
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitShellWords(t *testing.T) {
+	testcases := []struct {
+		in   string
+		want []string
+	}{
+		{in: "curl  example.com", want: []string{"curl", "example.com"}},
+		{in: `curl 'a b' "c \"d\" \$e" f\ g`, want: []string{"curl", "a b", `c "d" $e`, "f g"}},
+		{in: "curl \\\n  -L \\\r\n  example.com", want: []string{"curl", "-L", "example.com"}},
+		{in: `curl $'{"a":\n"it\'s"}' ''`, want: []string{"curl", "{\"a\":\n\"it's\"}", ""}},
+		{in: `curl $'\x41é'`, want: []string{"curl", "Aé"}},
+		{in: `curl 'a'"b"c`, want: []string{"curl", "abc"}},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.in, func(t *testing.T) {
+			got, err := splitShellWords(tc.in)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestParseCurl(t *testing.T) {
+	opts := NewDefaultOptions()
+	err := ParseCurl(`curl 'https://api.example.com/orders?page=1' \
+  -H 'accept: application/json' \
+  -H 'x-token: abc' \
+  -H 'User-Agent:' \
+  --data-raw '{"id":1}' \
+  --compressed -sSLk -u user:pass -m 2.5`, opts)
+
+	require.NoError(t, err)
+	assert.Equal(t, http.MethodPost, opts.Method)
+	assert.Equal(t, "https://api.example.com/orders?page=1", opts.URL)
+	assert.Equal(t, "application/json", opts.Header.Get("Accept"))
+	assert.Equal(t, "abc", opts.Header.Get("X-Token"))
+	assert.Contains(t, opts.Header, "User-Agent")
+	assert.Nil(t, opts.Header["User-Agent"])
+	assert.Equal(t, `{"id":1}`, string(opts.RawBody))
+	assert.Equal(t, "application/x-www-form-urlencoded", opts.ContentType)
+	assert.Equal(t, "user:pass", opts.Auth)
+	assert.True(t, opts.FollowRedirect)
+	assert.True(t, opts.Insecure)
+	assert.Equal(t, 2500*time.Millisecond, opts.timeout)
+}
+
+func TestParseCurl_methods(t *testing.T) {
+	testcases := []struct {
+		command    string
+		wantMethod string
+		wantQuery  url.Values
+	}{
+		{command: "curl example.com", wantMethod: http.MethodGet},
+		{command: "curl -XPUT example.com -d a=1", wantMethod: http.MethodPut},
+		{command: "curl --request DELETE example.com", wantMethod: http.MethodDelete},
+		{command: "curl -I example.com", wantMethod: http.MethodHead},
+		{command: "curl -G example.com -d a=1 --data-urlencode 'q=a b'", wantMethod: http.MethodGet, wantQuery: url.Values{"a": {"1"}, "q": {"a b"}}},
+		{command: "curl -F name=john example.com", wantMethod: http.MethodPost},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.command, func(t *testing.T) {
+			opts := NewDefaultOptions()
+			require.NoError(t, ParseCurl(tc.command, opts))
+			assert.Equal(t, tc.wantMethod, opts.Method)
+			if tc.wantQuery != nil {
+				assert.Equal(t, tc.wantQuery, opts.QueryParams)
+			}
+		})
+	}
+}
+
+func TestParseCurl_form(t *testing.T) {
+	opts := NewDefaultOptions()
+	err := ParseCurl(`curl example.com -F name=john -F 'bio=<testdata/item.txt' -F 'doc=@testdata/item.json;type=text/plain' --form-string 'raw=@x'`, opts)
+
+	require.NoError(t, err)
+	assert.True(t, opts.IsMultipart)
+	assert.Equal(t, url.Values{"name": {"john"}, "bio": {"file value\n"}, "raw": {"@x"}}, opts.FormData)
+	assert.Equal(t, []FormFile{{Field: "doc", Path: "testdata/item.json", ContentType: "text/plain"}}, opts.Files)
+}
+
+func TestParseCurl_errors(t *testing.T) {
+	testcases := []struct {
+		command string
+		wantErr string
+	}{
+		{command: "wget example.com", wantErr: "not a curl command: 'wget example.com'"},
+		{command: "curl", wantErr: "curl command must have exactly one URL, got 0"},
+		{command: "curl a.com b.com", wantErr: "curl command must have exactly one URL, got 2"},
+		{command: "curl --trace x example.com", wantErr: "unsupported curl option '--trace'"},
+		{command: "curl -Z example.com", wantErr: "unsupported curl option '-Z'"},
+		{command: "curl example.com -H", wantErr: "curl option '--header' needs a value"},
+		{command: "curl -u john example.com", wantErr: "curl option '--user john' has no password"},
+		{command: "curl -b cookies.txt example.com", wantErr: "curl cookie files are not supported: 'cookies.txt'"},
+		{command: "curl 'example.com", wantErr: "unterminated single quote in 'curl 'example.com'"},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.command, func(t *testing.T) {
+			err := ParseCurl(tc.command, NewDefaultOptions())
+			assert.EqualError(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestParseCurl_trace(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		b, err := io.ReadAll(req.Body)
+		require.NoError(t, err)
+		assert.Equal(t, http.MethodPost, req.Method)
+		assert.Equal(t, "a=1&b=2", string(b))
+		assert.Equal(t, "application/x-www-form-urlencoded", req.Header.Get("Content-Type"))
+		assert.Equal(t, "*/*", req.Header.Get("Accept"))
+		assert.Equal(t, "session=1", req.Header.Get("Cookie"))
+		assert.Equal(t, "extra", req.Header.Get("X-Extra"))
+	}))
+	defer svr.Close()
+
+	opts := NewDefaultOptions()
+	require.NoError(t, ParseCurl("curl "+svr.URL+" -d a=1 -d b=2 -b session=1", opts))
+	require.NoError(t, ParseItems([]string{"X-Extra:extra"}, opts))
+	r, err := Trace(context.Background(), opts)
+
+	require.NoError(t, err)
+	assert.Equal(t, "200", r.Status)
+}
@@ -0,0 +1,446 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode/utf8"
+)
+
+// exportFormats are the formats of --print-as.
+var exportFormats = []string{"curl", "httpie", "go", "python"}
+
+// exportedRequest is the request Trace would send, with its body read.
+type exportedRequest struct {
+	Method  string
+	URL     string
+	Headers []exportedHeader
+	Body    []byte
+	opts    *Options
+}
+
+type exportedHeader struct {
+	Name  string
+	Value string
+}
+
+// ExportRequest renders the request Trace would send for opts as a runnable
+// snippet in format, one of exportFormats.
+func ExportRequest(ctx context.Context, opts *Options, format string) (string, error) {
+	if !slices.Contains(exportFormats, format) {
+		return "", fmt.Errorf("unknown format '%s', must be one of: %s", format, strings.Join(exportFormats, ", "))
+	}
+	if opts.AuthType == authTypeDigest && opts.Auth != "" {
+		return "", fmt.Errorf("cannot export digest authentication, it needs the challenge of the server")
+	}
+
+	if opts.OAuth2 != nil {
+		token, _, err := opts.OAuth2.token(ctx, opts)
+		if err != nil {
+			return "", err
+		}
+		opts = opts.withHeader(authorizationHeader, "Bearer "+token)
+	}
+	req, err := newRequest(ctx, opts)
+	if err != nil {
+		return "", err
+	}
+	r := &exportedRequest{
+		Method: req.Method,
+		URL:    req.URL.String(),
+		opts:   opts,
+	}
+	if req.Body != nil {
+		r.Body, err = io.ReadAll(req.Body)
+		close(req.Body)
+		if err != nil {
+			return "", err
+		}
+	}
+	if opts.Jar != nil {
+		for _, c := range opts.Jar.Cookies(req.URL) {
+			req.AddCookie(c)
+		}
+	}
+	if _, ok := req.Header["User-Agent"]; !ok {
+		// the default of the Go client, sent by Trace.
+		req.Header.Set("User-Agent", "Go-http-client/1.1")
+	}
+	names := make([]string, 0, len(req.Header))
+	for name := range req.Header {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		for _, v := range req.Header[name] {
+			r.Headers = append(r.Headers, exportedHeader{Name: name, Value: v})
+		}
+	}
+
+	switch format {
+	case "curl":
+		return r.curl(), nil
+	case "httpie":
+		return r.httpie(), nil
+	case "go":
+		return r.goCode()
+	}
+
+	return r.python()
+}
+
+func (r *exportedRequest) header(name string) bool {
+	return slices.ContainsFunc(r.Headers, func(h exportedHeader) bool {
+		return h.Name == name
+	})
+}
+
+func (r *exportedRequest) curl() string {
+	args := []string{"curl"}
+	switch {
+	case r.Method == http.MethodHead:
+		args = append(args, "--head")
+	case r.Method != http.MethodGet || r.Body != nil:
+		args = append(args, "-X "+shellQuote(r.Method))
+	}
+	args = append(args, shellQuote(r.URL))
+	for _, h := range r.Headers {
+		if h.Value == "" {
+			args = append(args, "-H "+shellQuote(h.Name+";"))
+			continue
+		}
+		args = append(args, "-H "+shellQuote(h.Name+": "+h.Value))
+	}
+	// curl adds an Accept header unless it is removed.
+	if !r.header(acceptHeader) {
+		args = append(args, "-H "+shellQuote(acceptHeader+":"))
+	}
+	if r.Body != nil {
+		args = append(args, "--data-binary "+shellQuote(string(r.Body)))
+	}
+	if r.opts.FollowRedirect {
+		args = append(args, "--location")
+	}
+	if r.opts.Insecure {
+		args = append(args, "--insecure")
+	}
+	if r.opts.Proxy != nil {
+		args = append(args, "--proxy "+shellQuote(r.opts.Proxy.String()))
+	}
+	if r.opts.UnixSocket != "" {
+		args = append(args, "--unix-socket "+shellQuote(r.opts.UnixSocket))
+	}
+
+	return strings.Join(args, " \\\n  ") + "\n"
+}
+
+func (r *exportedRequest) httpie() string {
+	args := []string{"http"}
+	if r.opts.FollowRedirect {
+		args = append(args, "--follow")
+	}
+	if r.opts.Insecure {
+		args = append(args, "--verify=no")
+	}
+	if r.opts.Proxy != nil {
+		// HTTPie picks the proxy by the protocol of the request.
+		scheme, _, _ := strings.Cut(r.URL, "://")
+		args = append(args, shellQuote("--proxy="+scheme+":"+r.opts.Proxy.String()))
+	}
+	if r.Body != nil {
+		args = append(args, "--raw "+shellQuote(string(r.Body)))
+	}
+	u := r.URL
+	if r.opts.UnixSocket != "" {
+		// the http-unixsocket plugin scheme.
+		_, path, _ := strings.Cut(strings.TrimPrefix(u, "http://"), "/")
+		u = "http+unix://" + strings.ReplaceAll(r.opts.UnixSocket, "/", "%2F") + "/" + path
+	}
+	args = append(args, shellQuote(r.Method)+" "+shellQuote(u))
+	for _, h := range r.Headers {
+		if h.Value == "" {
+			args = append(args, shellQuote(h.Name+";"))
+			continue
+		}
+		args = append(args, shellQuote(h.Name+":"+h.Value))
+	}
+	// HTTPie adds an Accept header unless it is removed.
+	if !r.header(acceptHeader) {
+		args = append(args, shellQuote(acceptHeader+":"))
+	}
+
+	return strings.Join(args, " \\\n  ") + "\n"
+}
+
+var goTemplate = template.Must(template.New("go").Funcs(template.FuncMap{"quote": strconv.Quote}).Parse(`package main
+
+import (
+{{- if .UnixSocket }}
+	"context"
+{{- end }}
+{{- if .Insecure }}
+	"crypto/tls"
+{{- end }}
+	"fmt"
+	"io"
+{{- if .UnixSocket }}
+	"net"
+{{- end }}
+	"net/http"
+{{- if .Proxy }}
+	"net/url"
+{{- end }}
+{{- if .Body }}
+	"strings"
+{{- end }}
+)
+
+func main() {
+{{- if .Body }}
+	body := strings.NewReader({{ quote .Body }})
+	req, err := http.NewRequest({{ quote .Method }}, {{ quote .URL }}, body)
+{{- else }}
+	req, err := http.NewRequest({{ quote .Method }}, {{ quote .URL }}, nil)
+{{- end }}
+	if err != nil {
+		panic(err)
+	}
+{{- range .Headers }}
+	req.Header.Add({{ quote .Name }}, {{ quote .Value }})
+{{- end }}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+{{- if .Insecure }}
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+{{- end }}
+{{- if .Proxy }}
+	proxy, err := url.Parse({{ quote .Proxy }})
+	if err != nil {
+		panic(err)
+	}
+	transport.Proxy = http.ProxyURL(proxy)
+{{- end }}
+{{- if .UnixSocket }}
+	transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, "unix", {{ quote .UnixSocket }})
+	}
+{{- end }}
+	client := &http.Client{Transport: transport}
+{{- if not .FollowRedirect }}
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+{{- end }}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		panic(err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(resp.Status)
+	fmt.Println(string(b))
+}
+`))
+
+func (r *exportedRequest) goCode() (string, error) {
+	d := struct {
+		Method         string
+		URL            string
+		Headers        []exportedHeader
+		Body           string
+		FollowRedirect bool
+		Insecure       bool
+		Proxy          string
+		UnixSocket     string
+	}{
+		Method:         r.Method,
+		URL:            r.URL,
+		Headers:        r.Headers,
+		Body:           string(r.Body),
+		FollowRedirect: r.opts.FollowRedirect,
+		Insecure:       r.opts.Insecure,
+		UnixSocket:     r.opts.UnixSocket,
+	}
+	if r.opts.Proxy != nil {
+		d.Proxy = r.opts.Proxy.String()
+	}
+	var b strings.Builder
+	if err := goTemplate.Execute(&b, d); err != nil {
+		return "", err
+	}
+
+	return b.String(), nil
+}
+
+var pythonTemplate = template.Must(template.New("python").Parse(`import requests
+
+response = requests.request(
+    {{ .Method }},
+    {{ .URL }},
+    headers={
+{{- range .Headers }}
+        {{ .Name }}: {{ .Value }},
+{{- end }}
+    },
+{{- if .Body }}
+    data={{ .Body }},
+{{- end }}
+{{- if .Proxy }}
+    proxies={"http": {{ .Proxy }}, "https": {{ .Proxy }}},
+{{- end }}
+    allow_redirects={{ .FollowRedirect }},
+{{- if .Insecure }}
+    verify=False,
+{{- end }}
+)
+print(response.status_code, response.reason)
+print(response.text)
+`))
+
+func (r *exportedRequest) python() (string, error) {
+	if r.opts.UnixSocket != "" {
+		return "", fmt.Errorf("cannot export a request over a Unix socket as python")
+	}
+
+	type header struct {
+		Name  string
+		Value string
+	}
+	d := struct {
+		Method         string
+		URL            string
+		Headers        []header
+		Body           string
+		FollowRedirect string
+		Insecure       bool
+		Proxy          string
+	}{
+		Method:         pythonQuote([]byte(r.Method)),
+		URL:            pythonQuote([]byte(r.URL)),
+		FollowRedirect: "False",
+		Insecure:       r.opts.Insecure,
+	}
+	if r.opts.FollowRedirect {
+		d.FollowRedirect = "True"
+	}
+	if r.Body != nil {
+		d.Body = pythonBytes(r.Body)
+	}
+	if r.opts.Proxy != nil {
+		d.Proxy = pythonQuote([]byte(r.opts.Proxy.String()))
+	}
+	// a dict cannot repeat a header, so the values are joined.
+	for i := 0; i < len(r.Headers); i++ {
+		values := []string{r.Headers[i].Value}
+		for i+1 < len(r.Headers) && r.Headers[i+1].Name == r.Headers[i].Name {
+			i++
+			values = append(values, r.Headers[i].Value)
+		}
+		d.Headers = append(d.Headers, header{
+			Name:  pythonQuote([]byte(r.Headers[i].Name)),
+			Value: pythonQuote([]byte(strings.Join(values, ", "))),
+		})
+	}
+	// requests sends an Accept header unless it is None.
+	if !r.header(acceptHeader) {
+		d.Headers = append(d.Headers, header{Name: pythonQuote([]byte(acceptHeader)), Value: "None"})
+	}
+
+	var b strings.Builder
+	if err := pythonTemplate.Execute(&b, d); err != nil {
+		return "", err
+	}
+
+	return b.String(), nil
+}
+
+// pythonBytes returns a Python bytes expression of b. requests encodes a
+// str body as latin-1, which fails on other characters.
+func pythonBytes(b []byte) string {
+	q := pythonQuote(b)
+	if strings.HasPrefix(q, `b"`) {
+		return q
+	}
+
+	return q + ".encode()"
+}
+
+// shellQuote quotes s for a POSIX shell. Strings with control characters
+// other than a newline use the $'...' syntax.
+func shellQuote(s string) string {
+	special := false
+	plain := s != ""
+	for _, c := range []byte(s) {
+		if (c < 0x20 && c != '\n') || c == 0x7f {
+			special = true
+		}
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.IndexByte("-_./:=@,+%", c) >= 0) {
+			plain = false
+		}
+	}
+	if plain {
+		return s
+	}
+	if !special {
+		return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+	}
+
+	var b strings.Builder
+	b.WriteString("$'")
+	for _, c := range []byte(s) {
+		switch {
+		case c == '\'' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c == '\n':
+			b.WriteString(`\n`)
+		case c < 0x20 || c >= 0x7f:
+			fmt.Fprintf(&b, `\x%02x`, c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('\'')
+
+	return b.String()
+}
+
+// pythonQuote returns a Python literal of b: a string if it is valid UTF-8,
+// bytes otherwise.
+func pythonQuote(b []byte) string {
+	if utf8.Valid(b) {
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		_ = enc.Encode(string(b))
+		return strings.TrimSuffix(buf.String(), "\n")
+	}
+
+	var s strings.Builder
+	s.WriteString(`b"`)
+	for _, c := range b {
+		switch {
+		case c == '"' || c == '\\':
+			s.WriteByte('\\')
+			s.WriteByte(c)
+		case c < 0x20 || c >= 0x7f:
+			fmt.Fprintf(&s, `\x%02x`, c)
+		default:
+			s.WriteByte(c)
+		}
+	}
+	s.WriteByte('"')
+
+	return s.String()
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportRequest(t *testing.T) {
+	for _, format := range exportFormats {
+		t.Run(format, func(t *testing.T) {
+			opts := NewDefaultOptions()
+			require.NoError(t, ParseArgs([]string{"POST", "api.example.com/orders", "q==a b", "id:=1", "note=it's", "X-Tag:a", "X-Tag:b", "Accept:"}, opts))
+			opts.FollowRedirect = true
+			opts.Insecure = true
+			opts.Proxy = &url.URL{Scheme: "http", Host: "proxy:3128"}
+
+			s, err := ExportRequest(context.Background(), opts, format)
+
+			require.NoError(t, err)
+			goldenAssert(t, "export."+format+".golden", s)
+		})
+	}
+}
+
+func TestExportRequest_cookies(t *testing.T) {
+	s, err := LoadSession(t.TempDir()+"/session.json", "http://example.com")
+	require.NoError(t, err)
+	s.Cookies = []SessionCookie{{Name: "sid", Value: "1", Domain: "example.com", Path: "/"}}
+	opts := NewDefaultOptions()
+	opts.URL = "http://example.com"
+	require.NoError(t, s.Apply(opts))
+
+	out, err := ExportRequest(context.Background(), opts, "curl")
+
+	require.NoError(t, err)
+	assert.Contains(t, out, "-H 'Cookie: sid=1'")
+}
+
+func TestExportRequest_errors(t *testing.T) {
+	opts := NewDefaultOptions()
+	opts.URL = "http://example.com"
+
+	_, err := ExportRequest(context.Background(), opts, "wget")
+	assert.EqualError(t, err, "unknown format 'wget', must be one of: curl, httpie, go, python")
+
+	opts.UnixSocket = "/var/run/docker.sock"
+	_, err = ExportRequest(context.Background(), opts, "python")
+	assert.EqualError(t, err, "cannot export a request over a Unix socket as python")
+
+	opts.Auth = "user:pass"
+	opts.AuthType = authTypeDigest
+	_, err = ExportRequest(context.Background(), opts, "curl")
+	assert.EqualError(t, err, "cannot export digest authentication, it needs the challenge of the server")
+}
+
+func TestShellQuote(t *testing.T) {
+	testcases := []struct {
+		in   string
+		want string
+	}{
+		{in: "http://example.com/a", want: "http://example.com/a"},
+		{in: "", want: "''"},
+		{in: "a b", want: "'a b'"},
+		{in: "it's", want: `'it'\''s'`},
+		{in: "a\nb", want: "'a\nb'"},
+		{in: "a\tb'\\", want: `$'a\x09b\'\\'`},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.in, func(t *testing.T) {
+			assert.Equal(t, tc.want, shellQuote(tc.in))
+			words, err := splitShellWords(shellQuote(tc.in))
+			require.NoError(t, err)
+			assert.Equal(t, []string{tc.in}, words)
+		})
+	}
+}
+
+func TestPythonQuote(t *testing.T) {
+	assert.Equal(t, `"a \"b\" <c>"`, pythonQuote([]byte(`a "b" <c>`)))
+	assert.Equal(t, `b"\xff\x00a\""`, pythonQuote([]byte("\xff\x00a\"")))
+}
+
+func TestExportRequest_httpsProxy(t *testing.T) {
+	opts := NewDefaultOptions()
+	require.NoError(t, ParseArgs([]string{"https://api.example.com"}, opts))
+	opts.Proxy = &url.URL{Scheme: "http", Host: "proxy:3128"}
+
+	s, err := ExportRequest(context.Background(), opts, "httpie")
+
+	require.NoError(t, err)
+	assert.Contains(t, s, "--proxy=https:http://proxy:3128 \\\n")
+}
+
+func TestPythonBytes(t *testing.T) {
+	assert.Equal(t, `"{\"name\":\"Łódź €\"}".encode()`, pythonBytes([]byte(`{"name":"Łódź €"}`)))
+	assert.Equal(t, `b"\xff"`, pythonBytes([]byte("\xff")))
+}
+
+func TestExportRequest_method(t *testing.T) {
+	opts := NewDefaultOptions()
+	opts.URL = "http://example.com"
+	opts.Method = http.MethodHead
+
+	s, err := ExportRequest(context.Background(), opts, "curl")
+
+	require.NoError(t, err)
+	assert.Contains(t, s, "curl \\\n  --head \\\n  http://example.com")
+}
@@ -0,0 +1,544 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// HAR is an HTTP Archive, in the HAR 1.2 format.
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+// HARLog is the root of a HAR.
+type HARLog struct {
+	Version string     `json:"version"`
+	Creator HARCreator `json:"creator"`
+	Entries []HAREntry `json:"entries"`
+}
+
+// HARCreator is the application that created a HAR.
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// HAREntry is a request and its response.
+type HAREntry struct {
+	StartedDateTime time.Time   `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+	ServerIPAddress string      `json:"serverIPAddress,omitempty"`
+	Connection      string      `json:"connection,omitempty"`
+}
+
+// HARRequest is the request of an entry.
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	PostData    *HARPostData   `json:"postData,omitempty"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+// HARResponse is the response of an entry.
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+// HARNameValue is a header, a cookie, a query parameter or a form field.
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// HARPostData is the body of a request.
+type HARPostData struct {
+	MimeType string         `json:"mimeType"`
+	Text     string         `json:"text"`
+	Params   []HARNameValue `json:"params,omitempty"`
+}
+
+// HARContent is the body of a response.
+type HARContent struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// HARTimings are the phases of an entry, in milliseconds, or -1 when they
+// do not apply. Connect includes SSL.
+type HARTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+	SSL     float64 `json:"ssl"`
+}
+
+// harHopHeaders are the request headers of a HAR that are not replayed: the
+// HTTP/2 pseudo-headers and the ones set by the transport.
+var harHopHeaders = []string{"Host", "Content-Length", "Connection", "Keep-Alive", "Proxy-Connection", "Transfer-Encoding", "Te", "Upgrade"}
+
+// HARRecorder writes the traced requests to a HAR file. It is shared by
+// the clones of the options, so a file holds every request of a run.
+type HARRecorder struct {
+	path string
+	// tail follows the entries in the file, from the offset end.
+	tail []byte
+
+	mu      sync.Mutex
+	end     int64
+	entries int
+}
+
+// NewHARRecorder returns a recorder writing to path, and creates the file.
+func NewHARRecorder(path string) (*HARRecorder, error) {
+	version := "(devel)"
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		version = info.Main.Version
+	}
+	har := HAR{Log: HARLog{
+		Version: "1.2",
+		Creator: HARCreator{Name: "httpcheck", Version: version},
+		Entries: []HAREntry{},
+	}}
+	b, err := json.MarshalIndent(har, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	b = append(b, '\n')
+	// the entries are last, so the empty array is the last "[]".
+	end := bytes.LastIndex(b, []byte("[]")) + 1
+	h := &HARRecorder{path: path, tail: b[end+1:], end: int64(end)}
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		return nil, fmt.Errorf("cannot write HAR file: %w", err)
+	}
+
+	return h, nil
+}
+
+// Record appends the results to the file. Only the new entries and the
+// closing brackets are written, and the file is a complete HAR after each
+// result, even if a later request fails.
+func (h *HARRecorder) Record(results ...*Result) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var b bytes.Buffer
+	for _, r := range results {
+		e, err := NewHAREntry(r)
+		if err != nil {
+			return err
+		}
+		entry, err := json.MarshalIndent(e, "      ", "  ")
+		if err != nil {
+			return err
+		}
+		if h.entries > 0 || b.Len() > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString("\n      ")
+		b.Write(entry)
+	}
+	if b.Len() == 0 {
+		return nil
+	}
+	entries := b.Len()
+	b.WriteString("\n    ]")
+	b.Write(h.tail)
+
+	if err := h.writeAt(b.Bytes()); err != nil {
+		return fmt.Errorf("cannot write HAR file: %w", err)
+	}
+	h.end += int64(entries)
+	h.entries += len(results)
+
+	return nil
+}
+
+// writeAt writes b at the end of the entries. The file only grows, so the
+// previous closing brackets are overwritten.
+func (h *HARRecorder) writeAt(b []byte) error {
+	f, err := os.OpenFile(h.path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer close(f)
+	_, err = f.WriteAt(b, h.end)
+
+	return err
+}
+
+// NewHAREntry returns the HAR entry of r. The response body is read from
+// the output of r.
+func NewHAREntry(r *Result) (HAREntry, error) {
+	u, err := url.Parse(r.RequestURL)
+	if err != nil {
+		return HAREntry{}, err
+	}
+	requestHeader, responseHeader := http.Header{}, http.Header{}
+	for _, h := range r.RequestHeaders {
+		requestHeader.Add(h.Name, h.Value)
+	}
+	for _, h := range r.Headers {
+		responseHeader.Add(h.Name, h.Value)
+	}
+
+	req := HARRequest{
+		Method:      r.Method,
+		URL:         r.RequestURL,
+		HTTPVersion: r.HTTPVersion,
+		Cookies:     []HARNameValue{},
+		Headers:     harHeaders(r.RequestHeaders),
+		QueryString: []HARNameValue{},
+		HeadersSize: -1,
+		BodySize:    max(r.RequestBodySize, 0),
+	}
+	for _, c := range (&http.Request{Header: requestHeader}).Cookies() {
+		req.Cookies = append(req.Cookies, HARNameValue{Name: c.Name, Value: c.Value})
+	}
+	query := u.Query()
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		for _, v := range query[k] {
+			req.QueryString = append(req.QueryString, HARNameValue{Name: k, Value: v})
+		}
+	}
+	if r.RequestBody != nil {
+		req.PostData = &HARPostData{MimeType: requestHeader.Get(contentTypeHeader), Text: string(r.RequestBody)}
+	}
+
+	status, err := strconv.Atoi(r.Status)
+	if err != nil {
+		return HAREntry{}, fmt.Errorf("invalid status '%s'", r.Status)
+	}
+	resp := HARResponse{
+		Status:      status,
+		StatusText:  http.StatusText(status),
+		HTTPVersion: r.HTTPVersion,
+		Cookies:     []HARNameValue{},
+		Headers:     harHeaders(r.Headers),
+		Content: HARContent{
+			Size:     r.BodySize,
+			MimeType: responseHeader.Get(contentTypeHeader),
+		},
+		RedirectURL: responseHeader.Get("Location"),
+		HeadersSize: -1,
+		BodySize:    r.BodySize,
+	}
+	for _, c := range (&http.Response{Header: responseHeader}).Cookies() {
+		resp.Cookies = append(resp.Cookies, HARNameValue{Name: c.Name, Value: c.Value})
+	}
+	if r.Output != "" {
+		b, err := os.ReadFile(r.Output)
+		if err != nil {
+			return HAREntry{}, err
+		}
+		if utf8.Valid(b) {
+			resp.Content.Text = string(b)
+		} else {
+			resp.Content.Text = base64.StdEncoding.EncodeToString(b)
+			resp.Content.Encoding = "base64"
+		}
+	}
+
+	timings := HARTimings{
+		Blocked: 0,
+		DNS:     float64(r.MetricDNSLookup),
+		Connect: float64(r.MetricTCPConnection + r.MetricSocketConnect + r.MetricTLSHandshake),
+		Send:    float64(r.MetricRequestUpload),
+		Wait:    float64(r.MetricServerProcessing),
+		Receive: float64(r.MetricContentTransfer),
+		SSL:     -1,
+	}
+	if r.UnixSocket != "" {
+		timings.DNS = -1
+	}
+	if u.Scheme == "https" {
+		timings.SSL = float64(r.MetricTLSHandshake)
+	}
+
+	e := HAREntry{
+		StartedDateTime: r.StartedAt,
+		Time:            float64(r.Total()),
+		Request:         req,
+		Response:        resp,
+		Timings:         timings,
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		e.ServerIPAddress = host
+	}
+	if _, port, err := net.SplitHostPort(r.LocalAddr); err == nil {
+		e.Connection = port
+	}
+
+	return e, nil
+}
+
+func harHeaders(headers []Header) []HARNameValue {
+	values := make([]HARNameValue, len(headers))
+	for i, h := range headers {
+		values[i] = HARNameValue{Name: h.Name, Value: h.Value}
+	}
+
+	return values
+}
+
+// LoadHAR reads the HAR file at path, like the ones exported by browsers.
+func LoadHAR(path string) (*HAR, error) {
+	b, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	h := &HAR{}
+	if err := json.Unmarshal(b, h); err != nil {
+		return nil, fmt.Errorf("invalid HAR file '%s': %w", path, err)
+	}
+
+	return h, nil
+}
+
+// Name returns a short name of the request of e, like "GET example.com/a".
+func (e HAREntry) Name() string {
+	name := e.Request.URL
+	if u, err := url.Parse(e.Request.URL); err == nil {
+		name = u.Host + u.Path
+	}
+	if utf8.RuneCountInString(name) > 60 {
+		name = string([]rune(name)[:59]) + "…"
+	}
+
+	return e.Request.Method + " " + name
+}
+
+// Options returns the options replaying the request of e, based on base.
+// The request only has the headers of the entry.
+func (e HAREntry) Options(base *Options) (*Options, error) {
+	o := base.clone()
+	o.Method = e.Request.Method
+	o.URL = e.Request.URL
+
+	seen := map[string]bool{}
+	for _, h := range e.Request.Headers {
+		name := http.CanonicalHeaderKey(h.Name)
+		if strings.HasPrefix(name, ":") || slices.Contains(harHopHeaders, name) {
+			continue
+		}
+		if !seen[name] {
+			o.Header.Del(name)
+			seen[name] = true
+		}
+		o.Header.Add(name, h.Value)
+	}
+	for _, name := range []string{acceptHeader, contentTypeHeader} {
+		if _, ok := o.Header[name]; !ok {
+			o.Header[name] = nil
+		}
+	}
+
+	if p := e.Request.PostData; p != nil {
+		switch {
+		case p.Text != "":
+			o.RawBody = []byte(p.Text)
+		case len(p.Params) > 0:
+			o.IsForm = true
+			for _, param := range p.Params {
+				o.FormData.Add(param.Name, param.Value)
+			}
+		}
+		if p.MimeType != "" && o.Header.Get(contentTypeHeader) == "" {
+			o.Header.Set(contentTypeHeader, p.MimeType)
+		}
+	}
+
+	return o, nil
+}
+
+// Result returns the recorded timings of e. Phases that do not apply are
+// zero, and the SSL handshake is not part of the connection.
+func (e HAREntry) Result() *Result {
+	ms := func(v float64) int64 {
+		return int64(math.Round(max(v, 0)))
+	}
+	t := e.Timings
+
+	return &Result{
+		URL:                    e.Request.URL,
+		Method:                 e.Request.Method,
+		Status:                 strconv.Itoa(e.Response.Status),
+		HTTPVersion:            e.Response.HTTPVersion,
+		StartedAt:              e.StartedDateTime,
+		MetricDNSLookup:        ms(t.DNS),
+		MetricTCPConnection:    max(ms(t.Connect)-ms(t.SSL), 0),
+		MetricTLSHandshake:     ms(t.SSL),
+		MetricRequestUpload:    ms(t.Send),
+		MetricServerProcessing: ms(t.Wait),
+		MetricContentTransfer:  ms(t.Receive),
+	}
+}
+
+// Select returns the entries at the given positions, starting at 1, and
+// matching the URL filter, if any.
+func (h *HAR) Select(positions []string, match func(string) bool) ([]HAREntry, error) {
+	entries := h.Log.Entries
+	if len(positions) > 0 {
+		entries = nil
+		for _, p := range positions {
+			i, err := strconv.Atoi(p)
+			if err != nil || i < 1 || i > len(h.Log.Entries) {
+				return nil, fmt.Errorf("no entry at position '%s', the HAR file has %d entries", p, len(h.Log.Entries))
+			}
+			entries = append(entries, h.Log.Entries[i-1])
+		}
+	}
+	if match == nil {
+		return entries, nil
+	}
+
+	var selected []HAREntry
+	for _, e := range entries {
+		if match(e.Request.URL) {
+			selected = append(selected, e)
+		}
+	}
+
+	return selected, nil
+}
+
+// ReplayEntry is a replayed request and its recorded timings.
+type ReplayEntry struct {
+	Name     string
+	Recorded *Result
+	Result   *Result
+	Err      error
+}
+
+// Replay sends the requests of entries one after the other, and returns
+// their timings with the recorded ones.
+func Replay(ctx context.Context, entries []HAREntry, base *Options) []ReplayEntry {
+	replayed := make([]ReplayEntry, 0, len(entries))
+	for _, e := range entries {
+		r := ReplayEntry{Name: e.Name(), Recorded: e.Result()}
+		opts, err := e.Options(base)
+		if err == nil {
+			r.Result, err = Trace(ctx, opts)
+		}
+		r.Err = err
+		replayed = append(replayed, r)
+	}
+
+	return replayed
+}
+
+// PrintReplay writes a table comparing the timings of each replayed request
+// with the recorded ones.
+func PrintReplay(entries []ReplayEntry, opts ...PrintOption) error {
+	options := &printOptions{
+		out:   os.Stdout,
+		color: true,
+	}
+	for _, o := range opts {
+		o(options)
+	}
+	green, gray, red, cyan := green, gray, red, cyan
+	if !options.color {
+		green, gray, red, cyan = noColor, noColor, noColor, noColor
+	}
+
+	header := []string{"REQUEST", "STATUS"}
+	for _, c := range reportColumns {
+		header = append(header, c.name)
+	}
+	rows := [][]string{append(header, "DIFF")}
+	styles := []func(string) string{green}
+
+	var total, recorded int64
+	failed := 0
+	for _, e := range entries {
+		if e.Err != nil {
+			failed++
+			rows = append(rows, []string{e.Name, "error"})
+			styles = append(styles, red)
+		} else {
+			row := []string{e.Name, e.Result.Status}
+			for _, c := range reportColumns {
+				row = append(row, fmtms(c.metric(e.Result)))
+			}
+			diff := e.Result.Total() - e.Recorded.Total()
+			sign := "+"
+			if diff < 0 {
+				sign = ""
+			}
+			rows = append(rows, append(row, sign+fmtms(diff)))
+			styles = append(styles, nil)
+			total += e.Result.Total()
+			recorded += e.Recorded.Total()
+		}
+
+		row := []string{"  recorded", e.Recorded.Status}
+		for _, c := range reportColumns {
+			row = append(row, fmtms(c.metric(e.Recorded)))
+		}
+		rows = append(rows, row)
+		styles = append(styles, gray)
+	}
+
+	for i, line := range formatTable(rows) {
+		if styles[i] != nil {
+			line = styles[i](line)
+		}
+		fmt.Fprintln(options.out, line)
+	}
+	for _, e := range entries {
+		if e.Err != nil {
+			fmt.Fprintf(options.out, "%s %s: %s\n", red("error:"), e.Name, e.Err)
+		}
+	}
+
+	summary := fmt.Sprintf("%d requests, %d failed", len(entries), failed)
+	if failed < len(entries) {
+		summary += fmt.Sprintf(", %s in total, %s recorded", fmtms(total), fmtms(recorded))
+	}
+	_, err := fmt.Fprintf(options.out, "\n%s\n", cyan(summary))
+
+	return err
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHARRecorder(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		http.SetCookie(rw, &http.Cookie{Name: "sid", Value: "1"})
+		rw.Header().Set("Content-Type", "application/json")
+		_, err := io.Copy(rw, req.Body)
+		require.NoError(t, err)
+	}))
+	defer svr.Close()
+
+	path := filepath.Join(t.TempDir(), "trace.har")
+	recorder, err := NewHARRecorder(path)
+	require.NoError(t, err)
+	opts := NewDefaultOptions()
+	opts.Method = "POST"
+	opts.URL = svr.URL + "/items?b=2&a=1"
+	opts.RawBody = []byte(`{"id":1}`)
+	opts.HAR = recorder
+
+	_, err = Trace(context.Background(), opts)
+	require.NoError(t, err)
+
+	har, err := LoadHAR(path)
+	require.NoError(t, err)
+	assert.Equal(t, "1.2", har.Log.Version)
+	assert.Equal(t, "httpcheck", har.Log.Creator.Name)
+	require.Len(t, har.Log.Entries, 1)
+	e := har.Log.Entries[0]
+	assert.Equal(t, "POST", e.Request.Method)
+	assert.Equal(t, svr.URL+"/items?a=1&b=2", e.Request.URL)
+	assert.Equal(t, []HARNameValue{{Name: "a", Value: "1"}, {Name: "b", Value: "2"}}, e.Request.QueryString)
+	assert.Contains(t, e.Request.Headers, HARNameValue{Name: "Content-Type", Value: "application/json"})
+	assert.Equal(t, &HARPostData{MimeType: "application/json", Text: `{"id":1}`}, e.Request.PostData)
+	assert.Equal(t, int64(8), e.Request.BodySize)
+	assert.Equal(t, 200, e.Response.Status)
+	assert.Equal(t, "OK", e.Response.StatusText)
+	assert.Equal(t, []HARNameValue{{Name: "sid", Value: "1"}}, e.Response.Cookies)
+	assert.Equal(t, HARContent{Size: 8, MimeType: "application/json", Text: `{"id":1}`}, e.Response.Content)
+	assert.Equal(t, float64(-1), e.Timings.SSL)
+	assert.Equal(t, "127.0.0.1", e.ServerIPAddress)
+	assert.False(t, e.StartedDateTime.IsZero())
+}
+
+func TestHARRecorder_Record(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trace.har")
+	recorder, err := NewHARRecorder(path)
+	require.NoError(t, err)
+	output := filepath.Join(t.TempDir(), "body")
+	require.NoError(t, os.WriteFile(output, []byte("ok"), 0o600))
+	result := func(status string) *Result {
+		return &Result{Method: "GET", RequestURL: "http://example.com/" + status, Status: status, Output: output}
+	}
+
+	for _, statuses := range [][]string{{"200"}, {}, {"301", "404"}} {
+		var results []*Result
+		for _, status := range statuses {
+			results = append(results, result(status))
+		}
+		require.NoError(t, recorder.Record(results...))
+
+		// the file is a complete HAR, written as a whole would be.
+		har, err := LoadHAR(path)
+		require.NoError(t, err)
+		want, err := json.MarshalIndent(har, "", "  ")
+		require.NoError(t, err)
+		got, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, string(want)+"\n", string(got))
+	}
+	har, err := LoadHAR(path)
+	require.NoError(t, err)
+	var urls []string
+	for _, e := range har.Log.Entries {
+		urls = append(urls, e.Request.URL)
+	}
+	assert.Equal(t, []string{"http://example.com/200", "http://example.com/301", "http://example.com/404"}, urls)
+}
+
+func TestTrace_harError(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	defer svr.Close()
+	path := filepath.Join(t.TempDir(), "trace.har")
+	recorder, err := NewHARRecorder(path)
+	require.NoError(t, err)
+	require.NoError(t, os.Remove(path))
+	require.NoError(t, os.Mkdir(path, 0o700))
+	opts := NewDefaultOptions()
+	opts.URL = svr.URL
+	opts.HAR = recorder
+	var warned error
+	opts.Warn = func(err error) { warned = err }
+
+	r, err := Trace(context.Background(), opts)
+
+	require.NoError(t, err)
+	assert.Equal(t, "200", r.Status)
+	assert.ErrorContains(t, warned, "cannot write HAR file")
+}
+
+func TestNewHARRecorder_error(t *testing.T) {
+	_, err := NewHARRecorder(filepath.Join(t.TempDir(), "missing", "trace.har"))
+	assert.ErrorContains(t, err, "cannot write HAR file")
+}
+
+func TestHAREntry(t *testing.T) {
+	har, err := LoadHAR("testdata/browser.har")
+	require.NoError(t, err)
+	e := har.Log.Entries[0]
+
+	assert.Equal(t, "GET www.example.com/", e.Name())
+	r := e.Result()
+	assert.Equal(t, "200", r.Status)
+	assert.Equal(t, int64(12), r.MetricDNSLookup)
+	assert.Equal(t, int64(30), r.MetricTCPConnection)
+	assert.Equal(t, int64(30), r.MetricTLSHandshake)
+	assert.Equal(t, int64(96), r.MetricServerProcessing)
+	assert.Equal(t, int64(178), r.Total())
+
+	opts, err := e.Options(NewDefaultOptions())
+	require.NoError(t, err)
+	assert.Equal(t, "GET", opts.Method)
+	assert.Equal(t, "https://www.example.com/", opts.URL)
+	assert.Equal(t, "text/html", opts.Header.Get("Accept"))
+	assert.Equal(t, "sid=1", opts.Header.Get("Cookie"))
+	assert.NotContains(t, opts.Header, ":authority")
+	assert.Nil(t, opts.Header["Content-Type"])
+
+	opts, err = har.Log.Entries[1].Options(NewDefaultOptions())
+	require.NoError(t, err)
+	assert.Equal(t, `{"id": 42}`, string(opts.RawBody))
+	assert.Equal(t, "application/json", opts.Header.Get("Content-Type"))
+	assert.NotContains(t, opts.Header, "Content-Length")
+	assert.Equal(t, int64(0), har.Log.Entries[1].Result().MetricDNSLookup)
+}
+
+func TestHAR_Select(t *testing.T) {
+	har, err := LoadHAR("testdata/browser.har")
+	require.NoError(t, err)
+
+	entries, err := har.Select([]string{"2"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "POST", entries[0].Request.Method)
+
+	entries, err = har.Select(nil, regexp.MustCompile("/api/").MatchString)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	_, err = har.Select([]string{"3"}, nil)
+	assert.EqualError(t, err, "no entry at position '3', the HAR file has 2 entries")
+}
+
+func TestReplay(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "sid=1", req.Header.Get("Cookie"))
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer svr.Close()
+
+	har, err := LoadHAR("testdata/browser.har")
+	require.NoError(t, err)
+	e := har.Log.Entries[0]
+	e.Request.URL = svr.URL
+
+	replayed := Replay(context.Background(), []HAREntry{e}, NewDefaultOptions())
+
+	require.Len(t, replayed, 1)
+	require.NoError(t, replayed[0].Err)
+	assert.Equal(t, "204", replayed[0].Result.Status)
+	assert.Equal(t, "200", replayed[0].Recorded.Status)
+}
+
+func TestPrintReplay(t *testing.T) {
+	har, err := LoadHAR("testdata/browser.har")
+	require.NoError(t, err)
+	entries := []ReplayEntry{
+		{
+			Name:     har.Log.Entries[0].Name(),
+			Recorded: har.Log.Entries[0].Result(),
+			Result: &Result{
+				Status:                 "200",
+				MetricDNSLookup:        4,
+				MetricTCPConnection:    22,
+				MetricTLSHandshake:     25,
+				MetricServerProcessing: 80,
+				MetricContentTransfer:  3,
+			},
+		},
+		{
+			Name:     har.Log.Entries[1].Name(),
+			Recorded: har.Log.Entries[1].Result(),
+			Err:      errors.New("context deadline exceeded"),
+		},
+	}
+	var out bytes.Buffer
+
+	err = PrintReplay(entries, WithOut(&out), WithNoColor())
+
+	require.NoError(t, err)
+	goldenAssert(t, "replay.golden", out.String())
+}
@@ -0,0 +1,442 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HTTPFile is a .http or .rest file, in the format of the VS Code REST
+// Client and the JetBrains HTTP Client: requests separated by "###" lines,
+// each made of a request line, headers, an empty line and a body.
+type HTTPFile struct {
+	// Variables are the "@name = value" file variables.
+	Variables map[string]string
+	Requests  []HTTPFileRequest
+
+	dir string
+}
+
+// HTTPFileRequest is a single request of an HTTPFile. Its fields may still
+// reference variables.
+type HTTPFileRequest struct {
+	Name    string
+	Line    int
+	Method  string
+	URL     string
+	Headers []Header
+	Body    string
+	// BodyFile is the file holding the body, given by "< path". Variables
+	// are replaced in its content with "<@ path".
+	BodyFile         string
+	BodyFileVariable bool
+}
+
+var (
+	httpFileVariable = regexp.MustCompile(`^@([\w.-]+)\s*=\s*(.*)$`)
+	httpFileName     = regexp.MustCompile(`^(?:#|//)\s*@name\s+(\S+)`)
+	httpFileVersion  = regexp.MustCompile(`\s+HTTP/[\d.]+$`)
+	httpFileTemplate = regexp.MustCompile(`\{\{\s*(.*?)\s*\}\}`)
+)
+
+// LoadHTTPFile reads the .http file at path.
+func LoadHTTPFile(path string) (*HTTPFile, error) {
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	defer close(f)
+
+	file, err := ParseHTTPFile(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	file.dir = filepath.Dir(path)
+
+	return file, nil
+}
+
+// ParseHTTPFile parses the content of a .http file.
+func ParseHTTPFile(r io.Reader) (*HTTPFile, error) {
+	file := &HTTPFile{Variables: map[string]string{}}
+
+	const (
+		statePreamble = iota
+		stateHeaders
+		stateBody
+		stateHandler
+	)
+	var (
+		state   = statePreamble
+		req     *HTTPFileRequest
+		body    []string
+		name    string
+		lineNum int
+	)
+	flush := func() {
+		if req != nil {
+			// the empty lines before the next request are not part of the
+			// body.
+			for len(body) > 0 && strings.TrimSpace(body[len(body)-1]) == "" {
+				body = body[:len(body)-1]
+			}
+			if len(body) > 0 {
+				first := strings.TrimSpace(body[0])
+				switch {
+				case len(body) == 1 && strings.HasPrefix(first, "<@ "):
+					req.BodyFile = strings.TrimSpace(first[3:])
+					req.BodyFileVariable = true
+				case len(body) == 1 && strings.HasPrefix(first, "< "):
+					req.BodyFile = strings.TrimSpace(first[2:])
+				default:
+					req.Body = strings.Join(body, "\n")
+				}
+			}
+			file.Requests = append(file.Requests, *req)
+		}
+		state, req, body, name = statePreamble, nil, nil, ""
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		trimmed := strings.TrimSpace(line)
+
+		if strings.HasPrefix(trimmed, "###") {
+			flush()
+			name = strings.TrimSpace(strings.TrimLeft(trimmed, "#"))
+			continue
+		}
+
+		switch state {
+		case statePreamble:
+			switch {
+			case trimmed == "":
+			case httpFileName.MatchString(trimmed):
+				name = httpFileName.FindStringSubmatch(trimmed)[1]
+			case strings.HasPrefix(trimmed, "#"), strings.HasPrefix(trimmed, "//"):
+			case httpFileVariable.MatchString(trimmed):
+				m := httpFileVariable.FindStringSubmatch(trimmed)
+				file.Variables[m[1]] = strings.TrimSpace(m[2])
+			default:
+				req = &HTTPFileRequest{Name: name, Line: lineNum}
+				target := httpFileVersion.ReplaceAllString(trimmed, "")
+				if method, rest, ok := strings.Cut(target, " "); ok && looksLikeMethod(method) {
+					req.Method, req.URL = method, strings.TrimSpace(rest)
+				} else {
+					req.Method, req.URL = "GET", target
+				}
+				state = stateHeaders
+			}
+		case stateHeaders:
+			switch {
+			case trimmed == "":
+				state = stateBody
+			case strings.HasPrefix(trimmed, "?"), strings.HasPrefix(trimmed, "&"):
+				// a query string spanning multiple lines.
+				req.URL += httpFileVersion.ReplaceAllString(trimmed, "")
+			case strings.HasPrefix(trimmed, "#"), strings.HasPrefix(trimmed, "//"):
+			default:
+				k, v, ok := strings.Cut(line, ":")
+				if !ok {
+					return nil, fmt.Errorf("line %d: invalid header '%s'", lineNum, trimmed)
+				}
+				req.Headers = append(req.Headers, Header{Name: strings.TrimSpace(k), Value: strings.TrimSpace(v)})
+			}
+		case stateBody:
+			// JetBrains response handlers and redirections end the body.
+			if strings.HasPrefix(line, "> ") || strings.HasPrefix(line, ">>") {
+				state = stateHandler
+				continue
+			}
+			body = append(body, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+
+	return file, nil
+}
+
+// httpFileResolver replaces the "{{name}}" variables of a request.
+type httpFileResolver struct {
+	variables map[string]string
+	// values are substituted as is, without resolving the variables they
+	// may reference, like the values extracted from a response.
+	values map[string]string
+}
+
+// resolve replaces the variables of s. File variables can reference other
+// variables.
+func (r *httpFileResolver) resolve(s string) (string, error) {
+	return r.resolveEscaped(s, func(_, v, _ string) string { return v })
+}
+
+// resolveEscaped replaces the variables of s like resolve, and passes every
+// substituted value through escape, with the part of s already resolved and
+// the rest of s.
+func (r *httpFileResolver) resolveEscaped(s string, escape func(prefix, v, rest string) string) (string, error) {
+	var b strings.Builder
+	last := 0
+	for _, m := range httpFileTemplate.FindAllStringSubmatchIndex(s, -1) {
+		b.WriteString(s[last:m[0]])
+		v, err := r.value(s[m[2]:m[3]], 0)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(escape(b.String(), v, s[m[1]:]))
+		last = m[1]
+	}
+	b.WriteString(s[last:])
+
+	return b.String(), nil
+}
+
+func (r *httpFileResolver) resolveDepth(s string, depth int) (string, error) {
+	if depth > 10 {
+		return "", fmt.Errorf("too many nested variables in '%s'", s)
+	}
+
+	var err error
+	out := httpFileTemplate.ReplaceAllStringFunc(s, func(m string) string {
+		if err != nil {
+			return ""
+		}
+		var v string
+		v, err = r.value(httpFileTemplate.FindStringSubmatch(m)[1], depth)
+		return v
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return out, nil
+}
+
+// value returns the value of the expression of a "{{ ... }}": a system
+// variable, a variable, or an interpolation function like "uuid" or "env".
+func (r *httpFileResolver) value(expr string, depth int) (string, error) {
+	if strings.HasPrefix(expr, "$") {
+		return r.system(expr)
+	}
+	if v, ok := r.values[expr]; ok {
+		return v, nil
+	}
+	if v, ok := r.variables[expr]; ok {
+		return r.resolveDepth(v, depth+1)
+	}
+	if fields := strings.Fields(expr); len(fields) > 0 {
+		if _, ok := interpolationFuncs[fields[0]]; ok {
+			return renderTemplate("interpolation", "{{"+expr+"}}", interpolationFuncs, nil)
+		}
+	}
+
+	return "", fmt.Errorf("unknown variable '%s'", expr)
+}
+
+// system returns the value of a system variable, like "$guid" or
+// "$randomInt 1 10".
+func (r *httpFileResolver) system(expr string) (string, error) {
+	fields := strings.Fields(expr)
+	switch fields[0] {
+	case "$guid", "$uuid", "$random.uuid", "$randomUUID":
+		return newUUID()
+	case "$timestamp":
+		return strconv.FormatInt(time.Now().Unix(), 10), nil
+	case "$isoTimestamp":
+		return time.Now().UTC().Format(time.RFC3339), nil
+	case "$datetime":
+		layout := time.RFC3339
+		if len(fields) > 1 && fields[1] == "rfc1123" {
+			layout = time.RFC1123
+		}
+		return time.Now().UTC().Format(layout), nil
+	case "$randomInt", "$random.integer":
+		lo, hi := 0, 1000
+		if len(fields) == 3 {
+			var err error
+			if lo, err = strconv.Atoi(fields[1]); err != nil {
+				return "", fmt.Errorf("invalid variable '%s'", expr)
+			}
+			if hi, err = strconv.Atoi(fields[2]); err != nil {
+				return "", fmt.Errorf("invalid variable '%s'", expr)
+			}
+		}
+		return randInt(lo, hi)
+	case "$processEnv":
+		if len(fields) != 2 {
+			return "", fmt.Errorf("invalid variable '%s'", expr)
+		}
+		// a leading % reads the name of the variable from a file variable.
+		name := fields[1]
+		if v, ok := strings.CutPrefix(name, "%"); ok {
+			resolved, err := r.resolveDepth("{{"+v+"}}", 1)
+			if err != nil {
+				return "", err
+			}
+			name = resolved
+		}
+		return interpolateEnv(name)
+	}
+
+	return "", fmt.Errorf("unknown variable '%s'", expr)
+}
+
+// Options returns the options of req, based on base. The request only has
+// the headers of the file: the default Accept and Content-Type headers are
+// removed unless the file sets them.
+func (f *HTTPFile) Options(req HTTPFileRequest, base *Options, variables map[string]string) (*Options, error) {
+	r := &httpFileResolver{variables: make(map[string]string, len(f.Variables)+len(variables))}
+	for k, v := range f.Variables {
+		r.variables[k] = v
+	}
+	for k, v := range variables {
+		r.variables[k] = v
+	}
+
+	o := base.clone()
+	o.Method = req.Method
+	u, err := r.resolve(req.URL)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.Contains(u, "://") {
+		u = "http://" + u
+	}
+	o.URL = u
+
+	seen := map[string]bool{}
+	for _, h := range req.Headers {
+		name, err := r.resolve(h.Name)
+		if err != nil {
+			return nil, err
+		}
+		value, err := r.resolve(h.Value)
+		if err != nil {
+			return nil, err
+		}
+		// the headers of the file replace the ones of the config.
+		if name = http.CanonicalHeaderKey(name); !seen[name] {
+			o.Header.Del(name)
+			seen[name] = true
+		}
+		o.Header.Add(name, value)
+	}
+	for _, name := range []string{acceptHeader, contentTypeHeader} {
+		if _, ok := o.Header[name]; !ok {
+			o.Header[name] = nil
+		}
+	}
+
+	body := req.Body
+	if req.BodyFile != "" {
+		path := req.BodyFile
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(f.dir, path)
+		}
+		b, err := os.ReadFile(filepath.Clean(path))
+		if err != nil {
+			return nil, fmt.Errorf("cannot read '%s': %w", req.BodyFile, err)
+		}
+		if !req.BodyFileVariable {
+			o.RawBody = b
+			return o, nil
+		}
+		body = string(b)
+	}
+	if body != "" {
+		if body, err = r.resolve(body); err != nil {
+			return nil, err
+		}
+		o.RawBody = []byte(body)
+	}
+
+	return o, nil
+}
+
+// Select returns the requests matching names, either a request name or its
+// position starting at 1, or all the requests if there are no names.
+func (f *HTTPFile) Select(names []string) ([]HTTPFileRequest, error) {
+	if len(names) == 0 {
+		return f.Requests, nil
+	}
+
+	var selected []HTTPFileRequest
+	for _, name := range names {
+		found := false
+		for i, req := range f.Requests {
+			if req.Name == name || strconv.Itoa(i+1) == name {
+				selected = append(selected, req)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no request named '%s'", name)
+		}
+	}
+
+	return selected, nil
+}
+
+// Run traces the requests one after the other, sharing their cookies, and
+// prints each result. It returns an error if any request failed.
+func (f *HTTPFile) Run(ctx context.Context, requests []HTTPFileRequest, base *Options, variables map[string]string, out io.Writer, printOpts ...PrintOption) error {
+	base = base.clone()
+	if base.Jar == nil {
+		jar, err := newSessionJar()
+		if err != nil {
+			return err
+		}
+		base.Jar = jar
+	}
+
+	options := &printOptions{color: true}
+	for _, o := range printOpts {
+		o(options)
+	}
+	green, gray, red := green, gray, red
+	if !options.color {
+		green, gray, red = noColor, noColor, noColor
+	}
+
+	failed := 0
+	for i, req := range requests {
+		if i > 0 {
+			fmt.Fprintln(out)
+		}
+		title := req.Name
+		if title == "" {
+			title = fmt.Sprintf("request #%d", i+1)
+		}
+		fmt.Fprintf(out, "%s %s %s\n\n", green("###"), green(title), gray(fmt.Sprintf("(line %d)", req.Line)))
+
+		opts, err := f.Options(req, base, variables)
+		if err == nil {
+			var r *Result
+			if r, err = Trace(ctx, opts); err == nil {
+				err = PrintResult(r, append(printOpts, WithOut(out))...)
+			}
+		}
+		if err != nil {
+			failed++
+			fmt.Fprintf(out, "%s %s\n", red("error:"), err)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d requests failed", failed, len(requests))
+	}
+
+	return nil
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseHTTPFile(t *testing.T) {
+	f, err := LoadHTTPFile("testdata/requests.http")
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{"host": "{{baseUrl}}/api", "token": "secret"}, f.Variables)
+	assert.Equal(t, []HTTPFileRequest{
+		{
+			Name:   "list orders",
+			Line:   6,
+			Method: "GET",
+			URL:    "{{host}}/orders?page=1&size={{ $randomInt 10 11 }}",
+			Headers: []Header{
+				{Name: "Authorization", Value: "Bearer {{token}}"},
+				{Name: "Accept", Value: "application/json"},
+			},
+		},
+		{
+			Name:   "create",
+			Line:   16,
+			Method: "POST",
+			URL:    "{{host}}/orders",
+			Headers: []Header{
+				{Name: "Content-Type", Value: "application/json"},
+				{Name: "X-Request-Id", Value: "{{$guid}}"},
+			},
+			Body: "{\n    \"item\": \"book\",\n    \"token\": \"{{token}}\"\n}",
+		},
+		{
+			Name:             "upload",
+			Line:             29,
+			Method:           "PUT",
+			URL:              "{{host}}/orders/1",
+			Headers:          []Header{{Name: "Content-Type", Value: "application/json"}},
+			BodyFile:         "item.json",
+			BodyFileVariable: true,
+		},
+		{
+			Name:     "raw file",
+			Line:     35,
+			Method:   "PUT",
+			URL:      "{{host}}/orders/2",
+			BodyFile: "item.txt",
+		},
+	}, f.Requests)
+}
+
+func TestParseHTTPFile_errors(t *testing.T) {
+	_, err := ParseHTTPFile(strings.NewReader("GET example.com\nnot a header\n"))
+	assert.EqualError(t, err, "line 2: invalid header 'not a header'")
+}
+
+func TestHTTPFile_Options(t *testing.T) {
+	f, err := LoadHTTPFile("testdata/requests.http")
+	require.NoError(t, err)
+	base := NewDefaultOptions()
+	base.Header.Set("X-Team", "core")
+	base.Header.Set("Authorization", "from config")
+	vars := map[string]string{"baseUrl": "localhost:8080"}
+
+	opts, err := f.Options(f.Requests[0], base, vars)
+	require.NoError(t, err)
+	assert.Equal(t, "GET", opts.Method)
+	assert.Equal(t, "http://localhost:8080/api/orders?page=1&size=10", opts.URL)
+	assert.Equal(t, []string{"Bearer secret"}, opts.Header.Values("Authorization"))
+	assert.Equal(t, "core", opts.Header.Get("X-Team"))
+	assert.Nil(t, opts.Header["Content-Type"])
+	assert.Nil(t, opts.RawBody)
+	assert.Equal(t, "from config", base.Header.Get("Authorization"))
+
+	opts, err = f.Options(f.Requests[1], base, vars)
+	require.NoError(t, err)
+	assert.Equal(t, "{\n    \"item\": \"book\",\n    \"token\": \"secret\"\n}", string(opts.RawBody))
+	assert.Len(t, opts.Header.Get("X-Request-Id"), 36)
+	assert.Nil(t, opts.Header["Accept"])
+
+	opts, err = f.Options(f.Requests[2], base, vars)
+	require.NoError(t, err)
+	assert.Equal(t, "{\"k\": [1, 2]}\n", string(opts.RawBody))
+
+	opts, err = f.Options(f.Requests[3], base, vars)
+	require.NoError(t, err)
+	assert.Equal(t, "file value\n", string(opts.RawBody))
+
+	_, err = f.Options(f.Requests[0], base, nil)
+	assert.EqualError(t, err, "unknown variable 'baseUrl'")
+}
+
+func TestHTTPFile_Select(t *testing.T) {
+	f, err := LoadHTTPFile("testdata/requests.http")
+	require.NoError(t, err)
+
+	requests, err := f.Select([]string{"create", "4"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"create", "raw file"}, []string{requests[0].Name, requests[1].Name})
+
+	_, err = f.Select([]string{"delete"})
+	assert.EqualError(t, err, "no request named 'delete'")
+}
+
+func TestHTTPFile_Run(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/login":
+			http.SetCookie(rw, &http.Cookie{Name: "sid", Value: "1"})
+		case "/me":
+			_, err := req.Cookie("sid")
+			assert.NoError(t, err)
+			b, err := io.ReadAll(req.Body)
+			require.NoError(t, err)
+			assert.Equal(t, "hello", string(b))
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer svr.Close()
+
+	f, err := ParseHTTPFile(strings.NewReader(`
+POST {{host}}/login
+
+###
+# @name me
+PUT {{host}}/me
+
+hello
+
+###
+GET {{host}}/{{missing}}
+`))
+	require.NoError(t, err)
+	var out bytes.Buffer
+
+	err = f.Run(context.Background(), f.Requests, NewDefaultOptions(), map[string]string{"host": svr.URL}, &out, WithNoColor())
+
+	assert.EqualError(t, err, "1 of 3 requests failed")
+	assert.Contains(t, out.String(), "### request #1 (line 2)\n\nConnected to")
+	assert.Contains(t, out.String(), "### me (line 6)\n\nConnected to")
+	assert.Contains(t, out.String(), "### request #3 (line 11)\n\nerror: unknown variable 'missing'\n")
+}
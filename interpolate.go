@@ -0,0 +1,141 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// interpolationFuncs are the functions available in "{{ ... }}" actions.
+var interpolationFuncs = template.FuncMap{
+	"env":       interpolateEnv,
+	"uuid":      newUUID,
+	"timestamp": func() int64 { return time.Now().Unix() },
+	"randInt":   randInt,
+}
+
+// interpolate replaces the "${VAR}" environment variables and the
+// "{{ ... }}" template actions in s. Every substituted value goes through
+// escape, with the part of s already interpolated and the rest of s, so that
+// it is used literally. A backslash before "${" or "{{"
+// keeps them as is.
+func interpolate(s string, escape func(prefix, v, rest string) string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		rest := s[i:]
+		switch {
+		case strings.HasPrefix(rest, `\${`), strings.HasPrefix(rest, `\{{`):
+			b.WriteString(rest[1:3])
+			i += 2
+		case strings.HasPrefix(rest, "${"):
+			end := strings.IndexByte(rest, '}')
+			if end < 0 {
+				return "", fmt.Errorf("unclosed '${' in '%s'", s)
+			}
+			v, err := interpolateEnv(rest[2:end])
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(escape(b.String(), v, rest[end+1:]))
+			i += end
+		case strings.HasPrefix(rest, "{{"):
+			end := strings.Index(rest, "}}")
+			if end < 0 {
+				return "", fmt.Errorf("unclosed '{{' in '%s'", s)
+			}
+			v, err := renderTemplate("interpolation", rest[:end+2], interpolationFuncs, nil)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(escape(b.String(), v, rest[end+2:]))
+			i += end + 1
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+
+	return b.String(), nil
+}
+
+// escapeItem escapes the separator characters and the backslashes of s, so
+// that an interpolated value never splits a request item.
+func escapeItem(_, s, _ string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if strings.IndexByte(separatorChars, s[i]) >= 0 {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(s[i])
+	}
+
+	return b.String()
+}
+
+// escapeURL escapes s for its place in the URL starting with prefix and
+// followed by rest: a query component after "?", a path segment after the
+// host, a fragment, or a user name or password before the "@" of the host.
+// The scheme and the host are kept as is, so that a variable can hold a
+// base URL.
+func escapeURL(prefix, s, rest string) string {
+	if _, after, ok := strings.Cut(prefix, "://"); ok {
+		prefix = after
+	}
+	switch {
+	case strings.Contains(prefix, "#"):
+		return url.PathEscape(s)
+	case strings.Contains(prefix, "?"):
+		return url.QueryEscape(s)
+	case strings.Contains(prefix, "/"):
+		return url.PathEscape(s)
+	}
+	authority := rest
+	if i := strings.IndexAny(rest, "/?#"); i >= 0 {
+		authority = rest[:i]
+	}
+	if strings.Contains(authority, "@") {
+		// a password has the same escaping as a user name.
+		return strings.TrimPrefix(url.UserPassword("", s).String(), ":")
+	}
+
+	return s
+}
+
+func interpolateEnv(name string) (string, error) {
+	v, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("environment variable '%s' is not set", name)
+	}
+
+	return v, nil
+}
+
+// newUUID returns a random (version 4) UUID.
+func newUUID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+// randInt returns a random integer in [min, max).
+func randInt(min, max int) (string, error) {
+	if max <= min {
+		return "", fmt.Errorf("randInt: %d must be less than %d", min, max)
+	}
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(max-min)))
+	if err != nil {
+		return "", err
+	}
+
+	return strconv.FormatInt(n.Int64()+int64(min), 10), nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterpolate(t *testing.T) {
+	t.Setenv("HTTPCHECK_TOKEN", "a:b")
+	testcases := []struct {
+		in   string
+		want string
+	}{
+		{in: "plain", want: "plain"},
+		{in: "Bearer ${HTTPCHECK_TOKEN}", want: `Bearer a\:b`},
+		{in: `{{ env "HTTPCHECK_TOKEN" }}`, want: `a\:b`},
+		{in: `\${HTTPCHECK_TOKEN} \{{ uuid }}`, want: "${HTTPCHECK_TOKEN} {{ uuid }}"},
+		{in: "{{ randInt 7 8 }}", want: "7"},
+		{in: "$HOME {} }}", want: "$HOME {} }}"},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.in, func(t *testing.T) {
+			got, err := interpolate(tc.in, escapeItem)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestInterpolate_generated(t *testing.T) {
+	got, err := interpolate("{{ uuid }}", escapeItem)
+	require.NoError(t, err)
+	assert.Regexp(t, regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`), got)
+
+	other, err := interpolate("{{ uuid }}", escapeItem)
+	require.NoError(t, err)
+	assert.NotEqual(t, got, other)
+
+	got, err = interpolate("{{ timestamp }}", escapeItem)
+	require.NoError(t, err)
+	_, err = strconv.ParseInt(got, 10, 64)
+	assert.NoError(t, err)
+}
+
+func TestInterpolate_errors(t *testing.T) {
+	testcases := []struct {
+		in      string
+		wantErr string
+	}{
+		{in: "${HTTPCHECK_UNSET}", wantErr: "environment variable 'HTTPCHECK_UNSET' is not set"},
+		{in: "${HTTPCHECK_UNSET", wantErr: "unclosed '${' in '${HTTPCHECK_UNSET'"},
+		{in: "{{ uuid", wantErr: "unclosed '{{' in '{{ uuid'"},
+		{in: "{{ nope }}", wantErr: `invalid interpolation: template: interpolation:1: function "nope" not defined`},
+		{in: "{{ randInt 2 1 }}", wantErr: "invalid interpolation: template: interpolation:1:3: executing \"interpolation\" at <randInt 2 1>: error calling randInt: randInt: 2 must be less than 1"},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.in, func(t *testing.T) {
+			_, err := interpolate(tc.in, escapeItem)
+			assert.EqualError(t, err, tc.wantErr)
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// FormFile is a file uploaded in a multipart/form-data body.
+type FormFile struct {
+	Field       string
+	Path        string
+	ContentType string
+}
+
+// parseFormFile parses the value of a "field@path;type=mime" request item.
+func parseFormFile(field, value string) (FormFile, error) {
+	f := FormFile{Field: field, Path: value}
+	if i := strings.LastIndex(value, ";type="); i >= 0 {
+		f.Path, f.ContentType = value[:i], value[i+len(";type="):]
+	}
+	if rest, ok := strings.CutPrefix(f.Path, "~/"); ok {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return f, err
+		}
+		f.Path = filepath.Join(home, rest)
+	}
+	if f.Path == "" {
+		return f, fmt.Errorf("'%s' has no file to upload", field)
+	}
+	if f.ContentType == "" {
+		f.ContentType = mime.TypeByExtension(filepath.Ext(f.Path))
+	}
+	if f.ContentType == "" {
+		f.ContentType = "application/octet-stream"
+	}
+
+	return f, nil
+}
+
+// multipartBody streams form fields and files as a multipart/form-data body.
+type multipartBody struct {
+	fields   url.Values
+	files    []FormFile
+	boundary string
+}
+
+func newMultipartBody(fields url.Values, files []FormFile) *multipartBody {
+	return &multipartBody{
+		fields:   fields,
+		files:    files,
+		boundary: multipart.NewWriter(io.Discard).Boundary(),
+	}
+}
+
+// ContentType returns the Content-Type header value of the body.
+func (b *multipartBody) ContentType() string {
+	return "multipart/form-data; boundary=" + b.boundary
+}
+
+// ContentLength returns the size of the body without reading the files.
+func (b *multipartBody) ContentLength() (int64, error) {
+	c := &countingWriter{}
+	err := b.write(c, func(w io.Writer, f FormFile) error {
+		info, err := os.Stat(f.Path)
+		if err != nil {
+			return err
+		}
+		c.n += info.Size()
+
+		return nil
+	})
+
+	return c.n, err
+}
+
+// Reader returns a reader streaming the body. Files are read as the body is
+// consumed.
+func (b *multipartBody) Reader() io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(b.write(pw, func(w io.Writer, f FormFile) error {
+			file, err := os.Open(f.Path)
+			if err != nil {
+				return err
+			}
+			defer close(file)
+
+			_, err = io.Copy(w, file)
+			return err
+		}))
+	}()
+
+	return pr
+}
+
+func (b *multipartBody) write(w io.Writer, copyFile func(io.Writer, FormFile) error) error {
+	mw := multipart.NewWriter(w)
+	if err := mw.SetBoundary(b.boundary); err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(b.fields))
+	for k := range b.fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range b.fields[k] {
+			if err := mw.WriteField(k, v); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, f := range b.files {
+		h := textproto.MIMEHeader{}
+		h.Set("Content-Disposition", mime.FormatMediaType("form-data", map[string]string{
+			"name":     f.Field,
+			"filename": filepath.Base(f.Path),
+		}))
+		h.Set(contentTypeHeader, f.ContentType)
+		part, err := mw.CreatePart(h)
+		if err != nil {
+			return err
+		}
+		if err := copyFile(part, f); err != nil {
+			return err
+		}
+	}
+
+	return mw.Close()
+}
+
+type countingWriter struct {
+	n int64
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	c.n += int64(len(b))
+	return len(b), nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"io"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFormFile(t *testing.T) {
+	f, err := parseFormFile("doc", "testdata/item.json")
+	require.NoError(t, err)
+	assert.Equal(t, FormFile{Field: "doc", Path: "testdata/item.json", ContentType: "application/json"}, f)
+
+	f, err = parseFormFile("doc", "testdata/item.txt;type=text/csv")
+	require.NoError(t, err)
+	assert.Equal(t, FormFile{Field: "doc", Path: "testdata/item.txt", ContentType: "text/csv"}, f)
+
+	_, err = parseFormFile("doc", ";type=text/csv")
+	require.Error(t, err)
+}
+
+func TestMultipartBody_contentLength(t *testing.T) {
+	b := newMultipartBody(url.Values{"k": {"v"}}, []FormFile{
+		{Field: "a", Path: "testdata/item.txt", ContentType: "text/plain"},
+		{Field: "b", Path: "testdata/item.json", ContentType: "application/json"},
+	})
+
+	n, err := b.ContentLength()
+	require.NoError(t, err)
+	body, err := io.ReadAll(b.Reader())
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(body)), n)
+}
+
+func TestMultipartBody_missingFile(t *testing.T) {
+	b := newMultipartBody(nil, []FormFile{{Field: "a", Path: "testdata/missing.txt"}})
+
+	_, err := b.ContentLength()
+	require.Error(t, err)
+	_, err = io.ReadAll(b.Reader())
+	require.Error(t, err)
+}
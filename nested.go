@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maxIndexGap is how far past the end of an array an index can be, as the
+// gap is filled with nulls.
+const maxIndexGap = 1000
+
+// pathElem is a single element of a nested JSON path such as the "[name]"
+// in "user[name]". An element is either an object key, an array index, or
+// an append to an array.
+type pathElem struct {
+	key    string
+	index  int
+	isKey  bool
+	append bool
+}
+
+func (e pathElem) String() string {
+	switch {
+	case e.isKey:
+		return "[" + e.key + "]"
+	case e.append:
+		return "[]"
+	}
+
+	return "[" + strconv.Itoa(e.index) + "]"
+}
+
+// parseNestedPath parses the key of a data item into a nested JSON path.
+// Brackets and backslashes can be escaped with a backslash.
+//
+// examples:
+// "user[name]" -> user, [name]
+// "tags[]" -> tags, []
+// "items[0][id]" -> items, [0], [id]
+func parseNestedPath(s string) ([]pathElem, error) {
+	var (
+		path    []pathElem
+		b       strings.Builder
+		inside  bool
+		escaped bool
+		closed  bool
+	)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '\\' && i+1 < len(s) && strings.IndexByte(`[]\`, s[i+1]) >= 0 {
+			i++
+			c = s[i]
+			escaped = true
+		} else {
+			escaped = false
+		}
+
+		switch {
+		case escaped:
+			if closed && !inside {
+				return nil, fmt.Errorf("'%s' is not a valid nested path: unexpected '%c'", s, c)
+			}
+			b.WriteByte(c)
+		case c == '[' && !inside:
+			if len(path) == 0 && !closed {
+				if b.Len() == 0 {
+					return nil, fmt.Errorf("'%s' is not a valid nested path: top-level arrays are not supported", s)
+				}
+				path = append(path, pathElem{key: b.String(), isKey: true})
+				b.Reset()
+			}
+			inside = true
+		case c == ']' && inside:
+			path = append(path, newPathElem(b.String()))
+			b.Reset()
+			inside = false
+			closed = true
+		case c == '[' || c == ']':
+			return nil, fmt.Errorf("'%s' is not a valid nested path: unexpected '%c'", s, c)
+		default:
+			if closed && !inside {
+				return nil, fmt.Errorf("'%s' is not a valid nested path: unexpected '%c'", s, c)
+			}
+			b.WriteByte(c)
+		}
+	}
+	if inside {
+		return nil, fmt.Errorf("'%s' is not a valid nested path: missing ']'", s)
+	}
+	if len(path) == 0 {
+		path = append(path, pathElem{key: b.String(), isKey: true})
+	}
+
+	return path, nil
+}
+
+func newPathElem(s string) pathElem {
+	if s == "" {
+		return pathElem{append: true}
+	}
+	if i, err := strconv.Atoi(s); err == nil && i >= 0 && strconv.Itoa(i) == s {
+		return pathElem{index: i}
+	}
+
+	return pathElem{key: s, isKey: true}
+}
+
+// setNested sets v in data at the nested path described by key.
+func setNested(data map[string]any, key string, v any) error {
+	path, err := parseNestedPath(key)
+	if err != nil {
+		return err
+	}
+
+	root := path[0].key
+	value, err := setPath(data[root], path[1:], v, root)
+	if err != nil {
+		return err
+	}
+	data[root] = value
+
+	return nil
+}
+
+// setPath returns cur updated so that v is stored at path.
+func setPath(cur any, path []pathElem, v any, at string) (any, error) {
+	if len(path) == 0 {
+		return v, nil
+	}
+
+	elem := path[0]
+	next := at + elem.String()
+	if elem.isKey {
+		if cur == nil {
+			cur = map[string]any{}
+		}
+		m, ok := cur.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("cannot use '%s': '%s' is not an object", next, at)
+		}
+		value, err := setPath(m[elem.key], path[1:], v, next)
+		if err != nil {
+			return nil, err
+		}
+		m[elem.key] = value
+
+		return m, nil
+	}
+
+	if cur == nil {
+		cur = []any{}
+	}
+	s, ok := cur.([]any)
+	if !ok {
+		return nil, fmt.Errorf("cannot use '%s': '%s' is not an array", next, at)
+	}
+	if elem.append {
+		value, err := setPath(nil, path[1:], v, next)
+		if err != nil {
+			return nil, err
+		}
+
+		return append(s, value), nil
+	}
+	if elem.index-len(s) > maxIndexGap {
+		return nil, fmt.Errorf("cannot use '%s': the index is more than %d past the end of '%s'", next, maxIndexGap, at)
+	}
+	for len(s) <= elem.index {
+		s = append(s, nil)
+	}
+	value, err := setPath(s[elem.index], path[1:], v, next)
+	if err != nil {
+		return nil, err
+	}
+	s[elem.index] = value
+
+	return s, nil
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// packetSize is the largest chunk read or written at once on an emulated
+// link, so that bandwidth limits and packet delays apply at packet granularity.
+const packetSize = 1500
+
+// NetworkConditions describes the link emulated on the connection used by
+// Trace. The zero value leaves the connection untouched.
+type NetworkConditions struct {
+	// Latency is added once per round trip: when connecting, and when the
+	// first response bytes arrive after a request was written.
+	Latency time.Duration
+	// Jitter is the upper bound of a random delay added to Latency.
+	Jitter time.Duration
+	// PacketDelay is added to every packet read or written.
+	PacketDelay time.Duration
+	// Download and Upload limit the bandwidth in both directions.
+	Download Bitrate
+	Upload   Bitrate
+}
+
+var networkPresets = map[string]NetworkConditions{
+	"2g": {
+		Latency:  800 * time.Millisecond,
+		Download: 280 * Kbps,
+		Upload:   256 * Kbps,
+	},
+	"slow-3g": {
+		Latency:  400 * time.Millisecond,
+		Download: 400 * Kbps,
+		Upload:   400 * Kbps,
+	},
+	"3g": {
+		Latency:  150 * time.Millisecond,
+		Jitter:   20 * time.Millisecond,
+		Download: 1600 * Kbps,
+		Upload:   750 * Kbps,
+	},
+	"4g": {
+		Latency:  70 * time.Millisecond,
+		Jitter:   10 * time.Millisecond,
+		Download: 12 * Mbps,
+		Upload:   4 * Mbps,
+	},
+	"wifi": {
+		Latency:  5 * time.Millisecond,
+		Jitter:   2 * time.Millisecond,
+		Download: 30 * Mbps,
+		Upload:   15 * Mbps,
+	},
+}
+
+// NetworkPresets returns the names of the built-in network presets.
+func NetworkPresets() []string {
+	names := make([]string, 0, len(networkPresets))
+	for name := range networkPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// ApplyPreset copies the values of the named preset into n, except for the
+// values whose flag has been set explicitly.
+func (n *NetworkConditions) ApplyPreset(name string, changed func(flag string) bool) error {
+	p, ok := networkPresets[name]
+	if !ok {
+		return fmt.Errorf("unknown network preset '%s', must be one of: %s", name, strings.Join(NetworkPresets(), ", "))
+	}
+
+	if !changed("latency") {
+		n.Latency = p.Latency
+	}
+	if !changed("jitter") {
+		n.Jitter = p.Jitter
+	}
+	if !changed("packet-delay") {
+		n.PacketDelay = p.PacketDelay
+	}
+	if !changed("download") {
+		n.Download = p.Download
+	}
+	if !changed("upload") {
+		n.Upload = p.Upload
+	}
+
+	return nil
+}
+
+// IsZero reports whether no emulation is configured.
+func (n NetworkConditions) IsZero() bool {
+	return n == NetworkConditions{}
+}
+
+func (n NetworkConditions) latency() time.Duration {
+	d := n.Latency
+	if n.Jitter > 0 {
+		d += rand.N(n.Jitter)
+	}
+
+	return d
+}
+
+// control is used as net.Dialer.ControlContext so that the connection
+// latency is accounted to the connect phase.
+func (n NetworkConditions) control(ctx context.Context, _, _ string, _ syscall.RawConn) error {
+	return sleep(ctx, n.latency())
+}
+
+// dial wraps a dial function so that the returned connection is shaped.
+func (n NetworkConditions) dial(dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+
+		return &shapedConn{Conn: conn, cond: n}, nil
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// shapedConn is a net.Conn that delays and throttles the traffic according
+// to the network conditions.
+type shapedConn struct {
+	net.Conn
+	cond NetworkConditions
+
+	// turnaround is set once data has been written, so the next read pays
+	// for the round trip. Reads and writes happen on different goroutines.
+	turnaround atomic.Bool
+}
+
+func (c *shapedConn) Read(b []byte) (int, error) {
+	if len(b) > packetSize {
+		b = b[:packetSize]
+	}
+
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		if c.turnaround.Swap(false) {
+			time.Sleep(c.cond.latency())
+		}
+		time.Sleep(c.cond.PacketDelay + c.cond.Download.transferTime(n))
+	}
+
+	return n, err
+}
+
+func (c *shapedConn) Write(b []byte) (int, error) {
+	written := 0
+	for len(b) > 0 {
+		chunk := b[:min(len(b), packetSize)]
+		time.Sleep(c.cond.PacketDelay + c.cond.Upload.transferTime(len(chunk)))
+
+		n, err := c.Conn.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		b = b[n:]
+	}
+	c.turnaround.Store(true)
+
+	return written, nil
+}
+
+// Bitrate is a bandwidth in bits per second.
+type Bitrate int64
+
+// Bitrate units.
+const (
+	Bps  Bitrate = 1
+	Kbps         = 1000 * Bps
+	Mbps         = 1000 * Kbps
+	Gbps         = 1000 * Mbps
+)
+
+var bitrateUnits = []struct {
+	suffix string
+	unit   Bitrate
+}{
+	{"gbps", Gbps},
+	{"mbps", Mbps},
+	{"kbps", Kbps},
+	{"bps", Bps},
+	{"gbit", Gbps},
+	{"mbit", Mbps},
+	{"kbit", Kbps},
+	{"bit", Bps},
+}
+
+// ParseBitrate parses a bitrate such as "1.6mbps", "500kbit" or "64000".
+func ParseBitrate(s string) (Bitrate, error) {
+	v := strings.ToLower(strings.TrimSpace(s))
+	unit := Bps
+	for _, u := range bitrateUnits {
+		if strings.HasSuffix(v, u.suffix) {
+			v = strings.TrimSuffix(v, u.suffix)
+			unit = u.unit
+			break
+		}
+	}
+
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || f < 0 {
+		return 0, fmt.Errorf("'%s' is not a valid bitrate", s)
+	}
+
+	return Bitrate(f * float64(unit)), nil
+}
+
+func (b Bitrate) transferTime(n int) time.Duration {
+	if b <= 0 {
+		return 0
+	}
+
+	return time.Duration(int64(n) * 8 * int64(time.Second) / int64(b))
+}
+
+// String implements pflag.Value.
+func (b *Bitrate) String() string {
+	switch {
+	case *b == 0:
+		return ""
+	case *b%Mbps == 0:
+		return strconv.FormatInt(int64(*b/Mbps), 10) + "mbps"
+	case *b%Kbps == 0:
+		return strconv.FormatInt(int64(*b/Kbps), 10) + "kbps"
+	}
+
+	return strconv.FormatInt(int64(*b), 10) + "bps"
+}
+
+// Set implements pflag.Value.
+func (b *Bitrate) Set(s string) error {
+	v, err := ParseBitrate(s)
+	if err != nil {
+		return err
+	}
+	*b = v
+
+	return nil
+}
+
+// Type implements pflag.Value.
+func (b *Bitrate) Type() string {
+	return "bitrate"
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBitrate(t *testing.T) {
+	cases := []struct {
+		in   string
+		want Bitrate
+	}{
+		{in: "64000", want: 64000},
+		{in: "500kbps", want: 500 * Kbps},
+		{in: "1.6mbps", want: 1600 * Kbps},
+		{in: "2Mbit", want: 2 * Mbps},
+		{in: "1gbps", want: Gbps},
+	}
+	for _, tc := range cases {
+		t.Run(tc.in, func(t *testing.T) {
+			got, err := ParseBitrate(tc.in)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+
+	_, err := ParseBitrate("fast")
+	require.Error(t, err)
+}
+
+func TestNetworkConditions_ApplyPreset(t *testing.T) {
+	n := NetworkConditions{Latency: time.Second}
+	err := n.ApplyPreset("3g", func(flag string) bool {
+		return flag == "latency"
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, time.Second, n.Latency)
+	assert.Equal(t, networkPresets["3g"].Download, n.Download)
+	assert.Equal(t, networkPresets["3g"].Upload, n.Upload)
+
+	err = n.ApplyPreset("unknown", func(string) bool { return false })
+	require.Error(t, err)
+}
+
+func TestShapedConn_throttle(t *testing.T) {
+	client, server := net.Pipe()
+	defer close(server)
+	conn := &shapedConn{Conn: client, cond: NetworkConditions{Upload: 80 * Kbps}}
+	go func() {
+		_, _ = io.Copy(io.Discard, server)
+	}()
+
+	start := time.Now()
+	// 2000 bytes at 10000 bytes per second.
+	n, err := conn.Write(make([]byte, 2000))
+
+	require.NoError(t, err)
+	assert.Equal(t, 2000, n)
+	assert.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+}
+
+func TestTrace_network(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(rw, strings.Repeat("x", 1000))
+	}))
+	defer svr.Close()
+
+	opts := NewDefaultOptions()
+	opts.URL = svr.URL
+	opts.Network = NetworkConditions{
+		Latency:  50 * time.Millisecond,
+		Download: 80 * Kbps,
+	}
+	r, err := Trace(context.Background(), opts)
+
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, r.MetricTCPConnection, int64(50))
+	assert.GreaterOrEqual(t, r.MetricServerProcessing+r.MetricContentTransfer, int64(150))
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// oauth2ExpiryDelta is how long before its expiry a cached token stops being
+// used, so that it does not expire while the request is in flight.
+const oauth2ExpiryDelta = 30 * time.Second
+
+// OAuth2Config configures the OAuth2 client credentials grant used to get
+// the access token sent with the traced request.
+type OAuth2Config struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	// CredentialsInBody sends the client credentials as form fields instead
+	// of basic authentication.
+	CredentialsInBody bool
+	// NoCache disables the token cache.
+	NoCache bool
+
+	cacheDir string
+	now      func() time.Time
+}
+
+// NewOAuth2Config returns an OAuth2 configuration caching tokens in the
+// user cache directory.
+func NewOAuth2Config() *OAuth2Config {
+	return &OAuth2Config{
+		now: time.Now,
+	}
+}
+
+type oauth2Token struct {
+	AccessToken string    `json:"access_token"`
+	TokenType   string    `json:"token_type"`
+	Expiry      time.Time `json:"expiry"`
+}
+
+type oauth2TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// cachePath returns the file caching the tokens of c.
+func (c *OAuth2Config) cachePath() (string, error) {
+	dir := c.cacheDir
+	if dir == "" {
+		cache, err := os.UserCacheDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(cache, "httpcheck", "oauth2")
+	}
+
+	scopes := slices.Clone(c.Scopes)
+	slices.Sort(scopes)
+	// the secret is part of the key, so that a new one gets a new token.
+	sum := sha256.Sum256([]byte(strings.Join([]string{c.TokenURL, c.ClientID, c.ClientSecret, strings.Join(scopes, " ")}, "\n")))
+
+	return filepath.Join(dir, hex.EncodeToString(sum[:])+".json"), nil
+}
+
+func (c *OAuth2Config) cachedToken() *oauth2Token {
+	if c.NoCache {
+		return nil
+	}
+	path, err := c.cachePath()
+	if err != nil {
+		return nil
+	}
+	b, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil
+	}
+
+	var t oauth2Token
+	if err := json.Unmarshal(b, &t); err != nil {
+		return nil
+	}
+	if t.AccessToken == "" || (!t.Expiry.IsZero() && c.now().Add(oauth2ExpiryDelta).After(t.Expiry)) {
+		return nil
+	}
+
+	return &t
+}
+
+func (c *OAuth2Config) cacheToken(t *oauth2Token) error {
+	if c.NoCache || t.Expiry.IsZero() {
+		return nil
+	}
+	path, err := c.cachePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	b, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, b, 0o600)
+}
+
+// options returns the options of the token request, sent over the same
+// network conditions and proxy as opts, and as insecurely. The token
+// endpoint has its own host, so the unix socket of opts is not used.
+func (c *OAuth2Config) options(opts *Options) *Options {
+	o := NewDefaultOptions()
+	o.Method = http.MethodPost
+	o.URL = c.TokenURL
+	o.IsForm = true
+	o.timeout = opts.timeout
+	o.Network = opts.Network
+	o.Insecure = opts.Insecure
+	o.Proxy = opts.Proxy
+	o.Header.Set(acceptHeader, contentTypeJSON)
+	o.FormData.Set("grant_type", "client_credentials")
+	if len(c.Scopes) > 0 {
+		o.FormData.Set("scope", strings.Join(c.Scopes, " "))
+	}
+	if c.CredentialsInBody {
+		o.FormData.Set("client_id", c.ClientID)
+		o.FormData.Set("client_secret", c.ClientSecret)
+	} else {
+		o.Auth = url.QueryEscape(c.ClientID) + ":" + url.QueryEscape(c.ClientSecret)
+	}
+
+	return o
+}
+
+// token returns the access token, from the cache if it is still valid.
+// Otherwise the token request is traced and returned as a hop.
+func (c *OAuth2Config) token(ctx context.Context, opts *Options) (string, *Result, error) {
+	if t := c.cachedToken(); t != nil {
+		return t.AccessToken, nil, nil
+	}
+
+	tokenOpts := c.options(opts)
+	req, err := newRequest(ctx, tokenOpts)
+	if err != nil {
+		return "", nil, err
+	}
+	cli := newClient(tokenOpts)
+	defer cli.CloseIdleConnections()
+	r, err := traceRequest(cli, req, tokenOpts)
+	if err != nil {
+		return "", nil, fmt.Errorf("oauth2 token request failed: %w", err)
+	}
+
+	b, err := os.ReadFile(r.Output)
+	if err != nil {
+		return "", nil, err
+	}
+	if !strings.HasPrefix(r.Status, "2") {
+		return "", nil, fmt.Errorf("oauth2 token request failed with status %s: %s", r.Status, strings.TrimSpace(string(b)))
+	}
+	var resp oauth2TokenResponse
+	if err := json.Unmarshal(b, &resp); err != nil || resp.AccessToken == "" {
+		return "", nil, fmt.Errorf("oauth2 token response has no access_token: %s", strings.TrimSpace(string(b)))
+	}
+
+	t := &oauth2Token{
+		AccessToken: resp.AccessToken,
+		TokenType:   resp.TokenType,
+	}
+	if resp.ExpiresIn > 0 {
+		t.Expiry = c.now().Add(time.Duration(resp.ExpiresIn) * time.Second)
+	}
+	if err := c.cacheToken(t); err != nil {
+		return "", nil, err
+	}
+
+	return t.AccessToken, r, nil
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTokenServer(t *testing.T, requests *int) *httptest.Server {
+	return httptest.NewServer(tokenHandler(t, requests))
+}
+
+func tokenHandler(t *testing.T, requests *int) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		*requests++
+		require.NoError(t, req.ParseForm())
+		assert.Equal(t, "client_credentials", req.Form.Get("grant_type"))
+		assert.Equal(t, "read write", req.Form.Get("scope"))
+		id, secret, ok := req.BasicAuth()
+		if !ok || id != "app" || secret != "s3cret" {
+			rw.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(rw, `{"error": "invalid_client"}`)
+			return
+		}
+
+		rw.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(rw, `{"access_token": "token-%d", "token_type": "Bearer", "expires_in": 3600}`, *requests)
+	})
+}
+
+func TestTrace_oauth2(t *testing.T) {
+	var tokenRequests int
+	tokenSvr := newTokenServer(t, &tokenRequests)
+	defer tokenSvr.Close()
+	svr := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "Bearer token-1", req.Header.Get("Authorization"))
+	}))
+	defer svr.Close()
+
+	oauth2 := NewOAuth2Config()
+	oauth2.TokenURL = tokenSvr.URL
+	oauth2.ClientID = "app"
+	oauth2.ClientSecret = "s3cret"
+	oauth2.Scopes = []string{"read", "write"}
+	oauth2.cacheDir = t.TempDir()
+	opts := NewDefaultOptions()
+	opts.URL = svr.URL
+	opts.OAuth2 = oauth2
+
+	r, err := Trace(context.Background(), opts)
+	require.NoError(t, err)
+	require.Len(t, r.Hops, 1)
+	assert.Equal(t, "oauth2 token", r.Hops[0].Name)
+	assert.Equal(t, "200", r.Hops[0].Result.Status)
+
+	// the second request uses the cached token.
+	r, err = Trace(context.Background(), opts)
+	require.NoError(t, err)
+	assert.Empty(t, r.Hops)
+	assert.Equal(t, 1, tokenRequests)
+}
+
+func TestTrace_oauth2Insecure(t *testing.T) {
+	var tokenRequests int
+	tokenSvr := httptest.NewTLSServer(tokenHandler(t, &tokenRequests))
+	defer tokenSvr.Close()
+	svr := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "Bearer token-1", req.Header.Get("Authorization"))
+	}))
+	defer svr.Close()
+
+	oauth2 := NewOAuth2Config()
+	oauth2.TokenURL = tokenSvr.URL
+	oauth2.ClientID = "app"
+	oauth2.ClientSecret = "s3cret"
+	oauth2.Scopes = []string{"read", "write"}
+	oauth2.cacheDir = t.TempDir()
+	opts := NewDefaultOptions()
+	opts.URL = svr.URL
+	opts.OAuth2 = oauth2
+	opts.Insecure = true
+
+	r, err := Trace(context.Background(), opts)
+	require.NoError(t, err)
+	assert.Equal(t, "200", r.Status)
+	require.Len(t, r.Hops, 1)
+	assert.Equal(t, "200", r.Hops[0].Result.Status)
+}
+
+func TestTrace_oauth2UnixSocket(t *testing.T) {
+	var tokenRequests int
+	tokenSvr := newTokenServer(t, &tokenRequests)
+	defer tokenSvr.Close()
+	socket := filepath.Join(t.TempDir(), "httpcheck.sock")
+	l, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	svr := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "Bearer token-1", req.Header.Get("Authorization"))
+	}))
+	svr.Listener = l
+	svr.Start()
+	defer svr.Close()
+
+	oauth2 := NewOAuth2Config()
+	oauth2.TokenURL = tokenSvr.URL
+	oauth2.ClientID = "app"
+	oauth2.ClientSecret = "s3cret"
+	oauth2.Scopes = []string{"read", "write"}
+	oauth2.cacheDir = t.TempDir()
+	opts := NewDefaultOptions()
+	opts.URL = "http://example.com/info"
+	opts.UnixSocket = socket
+	opts.OAuth2 = oauth2
+
+	r, err := Trace(context.Background(), opts)
+	require.NoError(t, err)
+	assert.Equal(t, "200", r.Status)
+	require.Len(t, r.Hops, 1)
+	// the token request goes to the token endpoint, not to the socket.
+	hop := r.Hops[0].Result
+	assert.Equal(t, "200", hop.Status)
+	assert.Empty(t, hop.UnixSocket)
+	assert.Equal(t, tokenSvr.Listener.Addr().String(), hop.RemoteAddr)
+}
+
+func TestOAuth2Config_cacheKey(t *testing.T) {
+	oauth2 := NewOAuth2Config()
+	oauth2.TokenURL = "https://auth.example.com/token"
+	oauth2.ClientID = "app"
+	oauth2.ClientSecret = "s3cret"
+	oauth2.cacheDir = t.TempDir()
+	path, err := oauth2.cachePath()
+	require.NoError(t, err)
+
+	oauth2.ClientSecret = "rotated"
+	rotated, err := oauth2.cachePath()
+	require.NoError(t, err)
+	assert.NotEqual(t, path, rotated)
+	assert.NotContains(t, rotated, "rotated")
+}
+
+func TestOAuth2Config_expiredToken(t *testing.T) {
+	var tokenRequests int
+	tokenSvr := newTokenServer(t, &tokenRequests)
+	defer tokenSvr.Close()
+
+	oauth2 := NewOAuth2Config()
+	oauth2.TokenURL = tokenSvr.URL
+	oauth2.ClientID = "app"
+	oauth2.ClientSecret = "s3cret"
+	oauth2.Scopes = []string{"read", "write"}
+	oauth2.cacheDir = t.TempDir()
+	opts := NewDefaultOptions()
+
+	token, hop, err := oauth2.token(context.Background(), opts)
+	require.NoError(t, err)
+	assert.Equal(t, "token-1", token)
+	assert.NotNil(t, hop)
+
+	oauth2.now = func() time.Time {
+		return time.Now().Add(time.Hour)
+	}
+	token, hop, err = oauth2.token(context.Background(), opts)
+	require.NoError(t, err)
+	assert.Equal(t, "token-2", token)
+	assert.NotNil(t, hop)
+}
+
+func TestOAuth2Config_invalidClient(t *testing.T) {
+	var tokenRequests int
+	tokenSvr := newTokenServer(t, &tokenRequests)
+	defer tokenSvr.Close()
+
+	oauth2 := NewOAuth2Config()
+	oauth2.TokenURL = tokenSvr.URL
+	oauth2.ClientID = "app"
+	oauth2.ClientSecret = "wrong"
+	oauth2.Scopes = []string{"read", "write"}
+	oauth2.cacheDir = t.TempDir()
+
+	_, _, err := oauth2.token(context.Background(), NewDefaultOptions())
+	require.ErrorContains(t, err, "invalid_client")
+}
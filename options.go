@@ -3,7 +3,10 @@ package main
 import (
 	"net/http"
 	"net/url"
+	"slices"
 	"time"
+
+	"github.com/sirupsen/logrus"
 )
 
 // NewDefaultOptions returns default httpcheck options.
@@ -14,6 +17,7 @@ func NewDefaultOptions() *Options {
 		Data:        make(map[string]any),
 		FormData:    url.Values{},
 		QueryParams: url.Values{},
+		AuthType:    authTypeBasic,
 		timeout:     time.Second * 10,
 		maxBodySize: 1024,
 	}
@@ -22,15 +26,92 @@ func NewDefaultOptions() *Options {
 // Options configures httpstat.
 type Options struct {
 	Method         string
+	explicitMethod bool
 	URL            string
 	Header         http.Header
 	FormData       url.Values
 	Data           map[string]any
+	RawBody        []byte
+	ContentType    string
 	QueryParams    url.Values
+	Auth           string
+	AuthType       string
+	Signers        []Signer
+	OAuth2         *OAuth2Config
+	Jar            http.CookieJar
 	timeout        time.Duration
+	Files          []FormFile
 	FollowRedirect bool
 	IsForm         bool
+	IsMultipart    bool
+	UnixSocket     string
+	Insecure       bool
+	Proxy          *url.URL
+	Network        NetworkConditions
+	HAR            *HARRecorder
+	OTel           *OTelExporter
+	Push           *Pusher
+	// Warn reports the errors that do not fail a trace, such as a failed
+	// export. When nil, they are logged.
+	Warn func(error)
 
 	ShowBody    bool
 	maxBodySize int
 }
+
+// isMultipart reports whether the body is sent as multipart/form-data.
+func (o *Options) isMultipart() bool {
+	return o.IsMultipart || (o.IsForm && len(o.Files) > 0)
+}
+
+// warn reports err with o.Warn, or logs it.
+func (o *Options) warn(err error) {
+	if o.Warn != nil {
+		o.Warn(err)
+		return
+	}
+	logrus.Warn(err)
+}
+
+// withHeader returns a copy of o with the header name set to value.
+func (o *Options) withHeader(name, value string) *Options {
+	c := *o
+	c.Header = o.Header.Clone()
+	c.Header.Set(name, value)
+
+	return &c
+}
+
+// clone returns a copy of o that does not share its headers, data, form
+// data, query parameters or files. The signers, OAuth2 config and cookie jar are shared.
+func (o *Options) clone() *Options {
+	c := *o
+	c.Header = o.Header.Clone()
+	c.FormData = url.Values(http.Header(o.FormData).Clone())
+	c.QueryParams = url.Values(http.Header(o.QueryParams).Clone())
+	c.Data = cloneData(o.Data).(map[string]any)
+	c.Files = slices.Clone(o.Files)
+	c.Signers = slices.Clone(o.Signers)
+
+	return &c
+}
+
+// cloneData returns a deep copy of nested JSON data.
+func cloneData(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		c := make(map[string]any, len(v))
+		for k, e := range v {
+			c[k] = cloneData(e)
+		}
+		return c
+	case []any:
+		c := make([]any, len(v))
+		for i, e := range v {
+			c[i] = cloneData(e)
+		}
+		return c
+	}
+
+	return v
+}
@@ -0,0 +1,479 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	traceparentHeader = "Traceparent"
+
+	// otelExportTimeout is how long an export may take.
+	otelExportTimeout = 10 * time.Second
+	// otlpTracesPath is the path of the traces of an OTLP/HTTP endpoint, and
+	// otlpGRPCPath the method of the OTLP/gRPC trace service.
+	otlpTracesPath = "/v1/traces"
+	otlpGRPCPath   = "/opentelemetry.proto.collector.trace.v1.TraceService/Export"
+
+	spanKindInternal = 1
+	spanKindClient   = 3
+
+	spanStatusError = 2
+)
+
+// otelProtocols are the OTLP protocols of --otel-protocol.
+var otelProtocols = []string{"http/protobuf", "http/json", "grpc"}
+
+// OTelExporter exports each traced request as an OpenTelemetry trace, with
+// a client span for the request and a child span for each of its phases.
+type OTelExporter struct {
+	// Endpoint is the URL the traces are sent to.
+	Endpoint string
+	Protocol string
+	Header   http.Header
+	// Service is the service.name of the spans.
+	Service string
+
+	client *http.Client
+}
+
+// NewOTelExporter returns an exporter sending traces to endpoint with the
+// OTLP protocol, and headers, given as "name=value". The path of an
+// OTLP/HTTP endpoint defaults to /v1/traces.
+func NewOTelExporter(endpoint, protocol, service string, headers []string) (*OTelExporter, error) {
+	if !slices.Contains(otelProtocols, protocol) {
+		return nil, fmt.Errorf("unknown OTLP protocol '%s', must be one of: %s", protocol, strings.Join(otelProtocols, ", "))
+	}
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "http://" + endpoint
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid OTLP endpoint '%s'", endpoint)
+	}
+	switch {
+	case protocol == "grpc":
+		u.Path = otlpGRPCPath
+	case u.Path == "" || u.Path == "/":
+		u.Path = otlpTracesPath
+	}
+
+	e := &OTelExporter{
+		Endpoint: u.String(),
+		Protocol: protocol,
+		Header:   http.Header{},
+		Service:  service,
+		client:   &http.Client{Timeout: otelExportTimeout},
+	}
+	for _, h := range headers {
+		name, value, ok := strings.Cut(h, "=")
+		if !ok || !isToken(strings.TrimSpace(name)) {
+			return nil, fmt.Errorf("invalid OTLP header '%s', use name=value", h)
+		}
+		e.Header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+	if protocol == "grpc" {
+		if e.client.Transport, err = newGRPCTransport(u.Scheme); err != nil {
+			return nil, err
+		}
+	}
+
+	return e, nil
+}
+
+// SpanContext identifies a span of a trace.
+type SpanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+}
+
+// NewSpanContext returns a new span of the trace of parent, the value of a
+// traceparent header, or of a new trace if parent is not a valid one.
+func NewSpanContext(parent string) (sc SpanContext, parentID [8]byte) {
+	if p, ok := parseTraceparent(parent); ok {
+		sc.TraceID, parentID = p.TraceID, p.SpanID
+	} else {
+		_, _ = rand.Read(sc.TraceID[:])
+	}
+	_, _ = rand.Read(sc.SpanID[:])
+
+	return sc, parentID
+}
+
+// Traceparent returns the W3C traceparent header of sc, as sampled.
+func (sc SpanContext) Traceparent() string {
+	return "00-" + hex.EncodeToString(sc.TraceID[:]) + "-" + hex.EncodeToString(sc.SpanID[:]) + "-01"
+}
+
+// parseTraceparent parses a version 00 W3C traceparent header.
+func parseTraceparent(s string) (SpanContext, bool) {
+	var sc SpanContext
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) != 4 || parts[0] != "00" || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return sc, false
+	}
+
+	return sc, sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+// otelSpan is a span of OTLP.
+type otelSpan struct {
+	TraceID    [16]byte
+	SpanID     [8]byte
+	ParentID   [8]byte
+	Name       string
+	Kind       int
+	Start      time.Time
+	End        time.Time
+	Attributes []otelAttribute
+	// StatusCode is 0 when unset or spanStatusError.
+	StatusCode    int
+	StatusMessage string
+}
+
+// otelAttribute is an attribute of a span, whose value is a string, an
+// int64 or a bool.
+type otelAttribute struct {
+	Key   string
+	Value any
+}
+
+// traceSpans returns the spans of a traced request, r or err: the client
+// span sc, from start to the end of the response, and a child span for each
+// phase. Hops are child spans with their own phases.
+func traceSpans(sc SpanContext, parentID [8]byte, method, rawURL string, start time.Time, r *Result, err error) []otelSpan {
+	if r != nil {
+		rawURL = r.RequestURL
+	}
+	root := otelSpan{
+		TraceID:  sc.TraceID,
+		SpanID:   sc.SpanID,
+		ParentID: parentID,
+		Name:     method,
+		Kind:     spanKindClient,
+		Start:    start,
+		End:      time.Now(),
+	}
+	root.Attributes = append(root.Attributes, otelAttribute{"http.request.method", method}, otelAttribute{"url.full", redactURL(rawURL)})
+	if u, err := url.Parse(rawURL); err == nil {
+		root.Attributes = append(root.Attributes, otelAttribute{"server.address", u.Hostname()})
+		port := u.Port()
+		if port == "" && u.Scheme == "https" {
+			port = "443"
+		} else if port == "" {
+			port = "80"
+		}
+		if n, err := strconv.Atoi(port); err == nil {
+			root.Attributes = append(root.Attributes, otelAttribute{"server.port", int64(n)})
+		}
+	}
+	if err != nil {
+		root.StatusCode = spanStatusError
+		root.StatusMessage = err.Error()
+		root.Attributes = append(root.Attributes, otelAttribute{"error.type", fmt.Sprintf("%T", err)})
+
+		return []otelSpan{root}
+	}
+
+	root.End = r.EndedAt
+	status, _ := strconv.Atoi(r.Status)
+	root.Attributes = append(root.Attributes,
+		otelAttribute{"http.response.status_code", int64(status)},
+		otelAttribute{"network.protocol.version", strings.TrimPrefix(r.HTTPVersion, "HTTP/")},
+		otelAttribute{"http.response.body.size", r.BodySize},
+	)
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		root.Attributes = append(root.Attributes, otelAttribute{"network.peer.address", host})
+	}
+	if r.RequestBodySize > 0 {
+		root.Attributes = append(root.Attributes, otelAttribute{"http.request.body.size", r.RequestBodySize})
+	}
+	if status >= 400 {
+		root.StatusCode = spanStatusError
+		root.Attributes = append(root.Attributes, otelAttribute{"error.type", r.Status})
+	}
+
+	spans := []otelSpan{root}
+	for _, h := range r.Hops {
+		hop := childSpan(root, h.Name, h.Result.StartedAt, h.Result.EndedAt)
+		status, _ := strconv.Atoi(h.Result.Status)
+		hop.Attributes = []otelAttribute{{"url.full", redactURL(h.Result.RequestURL)}, {"http.response.status_code", int64(status)}}
+		spans = append(spans, hop)
+		for _, t := range h.Result.Timings {
+			spans = append(spans, childSpan(hop, t.Name, t.Start, t.End))
+		}
+	}
+	for _, t := range r.Timings {
+		spans = append(spans, childSpan(root, t.Name, t.Start, t.End))
+	}
+
+	return spans
+}
+
+// redactURL returns rawURL with its credentials replaced by "REDACTED", as
+// the semantic conventions ask for url.full.
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.User == nil {
+		return rawURL
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword("REDACTED", "REDACTED")
+	} else {
+		u.User = url.User("REDACTED")
+	}
+
+	return u.String()
+}
+
+func childSpan(parent otelSpan, name string, start, end time.Time) otelSpan {
+	s := otelSpan{
+		TraceID:  parent.TraceID,
+		ParentID: parent.SpanID,
+		Name:     name,
+		Kind:     spanKindInternal,
+		Start:    start,
+		End:      end,
+	}
+	_, _ = rand.Read(s.SpanID[:])
+
+	return s
+}
+
+// Export sends spans to the endpoint of e.
+func (e *OTelExporter) Export(ctx context.Context, spans []otelSpan) error {
+	// the request may be canceled, like at its timeout, but not its trace.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), otelExportTimeout)
+	defer cancel()
+
+	var body []byte
+	var contentType string
+	switch e.Protocol {
+	case "http/json":
+		b, err := json.Marshal(e.jsonRequest(spans))
+		if err != nil {
+			return err
+		}
+		body, contentType = b, contentTypeJSON
+	case "http/protobuf":
+		body, contentType = e.protoRequest(spans), "application/x-protobuf"
+	case "grpc":
+		msg := e.protoRequest(spans)
+		// a gRPC message is prefixed by an uncompressed flag and its length.
+		body = binary.BigEndian.AppendUint32([]byte{0}, uint32(len(msg)))
+		body, contentType = append(body, msg...), "application/grpc"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for name, values := range e.Header {
+		req.Header[name] = values
+	}
+	req.Header.Set(contentTypeHeader, contentType)
+	if e.Protocol == "grpc" {
+		req.Header.Set("Te", "trailers")
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("cannot export the trace: %w", err)
+	}
+	defer close(resp.Body)
+	b, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if err != nil {
+		return fmt.Errorf("cannot export the trace: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("cannot export the trace: status %d: %s", resp.StatusCode, strings.TrimSpace(string(b)))
+	}
+	if e.Protocol == "grpc" {
+		// the status is a trailer, or a header of a response without body.
+		status, message := resp.Trailer.Get("Grpc-Status"), resp.Trailer.Get("Grpc-Message")
+		if status == "" {
+			status, message = resp.Header.Get("Grpc-Status"), resp.Header.Get("Grpc-Message")
+		}
+		if status != "0" {
+			return fmt.Errorf("cannot export the trace: grpc status %s: %s", status, message)
+		}
+	}
+
+	return nil
+}
+
+// jsonRequest returns the OTLP/JSON ExportTraceServiceRequest of spans.
+func (e *OTelExporter) jsonRequest(spans []otelSpan) any {
+	type object = map[string]any
+	attributes := func(attrs []otelAttribute) []object {
+		values := make([]object, len(attrs))
+		for i, a := range attrs {
+			var v object
+			switch value := a.Value.(type) {
+			case string:
+				v = object{"stringValue": value}
+			case int64:
+				// 64-bit integers are strings in JSON.
+				v = object{"intValue": strconv.FormatInt(value, 10)}
+			case bool:
+				v = object{"boolValue": value}
+			}
+			values[i] = object{"key": a.Key, "value": v}
+		}
+		return values
+	}
+
+	jsonSpans := make([]object, len(spans))
+	for i, s := range spans {
+		span := object{
+			"traceId":           hex.EncodeToString(s.TraceID[:]),
+			"spanId":            hex.EncodeToString(s.SpanID[:]),
+			"name":              s.Name,
+			"kind":              s.Kind,
+			"startTimeUnixNano": strconv.FormatInt(s.Start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(s.End.UnixNano(), 10),
+			"attributes":        attributes(s.Attributes),
+			"status":            object{},
+		}
+		if s.ParentID != [8]byte{} {
+			span["parentSpanId"] = hex.EncodeToString(s.ParentID[:])
+		}
+		if s.StatusCode != 0 {
+			span["status"] = object{"code": s.StatusCode, "message": s.StatusMessage}
+		}
+		jsonSpans[i] = span
+	}
+
+	return object{"resourceSpans": []object{{
+		"resource": object{"attributes": attributes(e.resource())},
+		"scopeSpans": []object{{
+			"scope": object{"name": "httpcheck"},
+			"spans": jsonSpans,
+		}},
+	}}}
+}
+
+func (e *OTelExporter) resource() []otelAttribute {
+	return []otelAttribute{{"service.name", e.Service}}
+}
+
+// protoRequest returns the protobuf ExportTraceServiceRequest of spans.
+func (e *OTelExporter) protoRequest(spans []otelSpan) []byte {
+	attribute := func(a otelAttribute) []byte {
+		var v protoBuffer
+		switch value := a.Value.(type) {
+		case string:
+			v.string(1, value)
+		case bool:
+			var n uint64
+			if value {
+				n = 1
+			}
+			v.oneofVarint(2, n)
+		case int64:
+			v.oneofVarint(3, uint64(value))
+		}
+		var kv protoBuffer
+		kv.string(1, a.Key)
+		kv.message(2, v)
+		return kv
+	}
+
+	var scope protoBuffer
+	var scopeName protoBuffer
+	scopeName.string(1, "httpcheck")
+	scope.message(1, scopeName)
+	for _, s := range spans {
+		var span protoBuffer
+		span.bytes(1, s.TraceID[:])
+		span.bytes(2, s.SpanID[:])
+		if s.ParentID != [8]byte{} {
+			span.bytes(4, s.ParentID[:])
+		}
+		span.string(5, s.Name)
+		span.varint(6, uint64(s.Kind))
+		span.fixed64(7, uint64(s.Start.UnixNano()))
+		span.fixed64(8, uint64(s.End.UnixNano()))
+		for _, a := range s.Attributes {
+			span.message(9, attribute(a))
+		}
+		var status protoBuffer
+		if s.StatusCode != 0 {
+			status.string(2, s.StatusMessage)
+			status.varint(3, uint64(s.StatusCode))
+		}
+		span.message(15, status)
+		scope.message(2, span)
+	}
+
+	var resource protoBuffer
+	for _, a := range e.resource() {
+		resource.message(1, attribute(a))
+	}
+	var resourceSpans protoBuffer
+	resourceSpans.message(1, resource)
+	resourceSpans.message(2, scope)
+	var req protoBuffer
+	req.message(1, resourceSpans)
+
+	return req
+}
+
+// protoBuffer encodes the fields of a protobuf message.
+type protoBuffer []byte
+
+func (b *protoBuffer) tag(field, wireType int) {
+	*b = binary.AppendUvarint(*b, uint64(field<<3|wireType))
+}
+
+// varint writes v, unless it is the default of zero.
+func (b *protoBuffer) varint(field int, v uint64) {
+	if v == 0 {
+		return
+	}
+	b.oneofVarint(field, v)
+}
+
+// oneofVarint writes v even when it is zero, as the field of a oneof is
+// set by its presence.
+func (b *protoBuffer) oneofVarint(field int, v uint64) {
+	b.tag(field, 0)
+	*b = binary.AppendUvarint(*b, v)
+}
+
+func (b *protoBuffer) fixed64(field int, v uint64) {
+	b.tag(field, 1)
+	*b = binary.LittleEndian.AppendUint64(*b, v)
+}
+
+func (b *protoBuffer) bytes(field int, v []byte) {
+	b.tag(field, 2)
+	*b = binary.AppendUvarint(*b, uint64(len(v)))
+	*b = append(*b, v...)
+}
+
+func (b *protoBuffer) string(field int, v string) {
+	b.bytes(field, []byte(v))
+}
+
+func (b *protoBuffer) message(field int, m protoBuffer) {
+	b.bytes(field, m)
+}
@@ -0,0 +1,18 @@
+//go:build go1.24
+
+package main
+
+import (
+	"net/http"
+)
+
+// newGRPCTransport returns the HTTP/2 transport of OTLP/gRPC, over TLS for
+// https endpoints and in cleartext for http ones.
+func newGRPCTransport(_ string) (http.RoundTripper, error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Protocols = new(http.Protocols)
+	t.Protocols.SetHTTP2(true)
+	t.Protocols.SetUnencryptedHTTP2(true)
+
+	return t, nil
+}
@@ -0,0 +1,20 @@
+//go:build !go1.24
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// newGRPCTransport returns the HTTP/2 transport of OTLP/gRPC. Before Go
+// 1.24, the standard library only speaks HTTP/2 over TLS.
+func newGRPCTransport(scheme string) (http.RoundTripper, error) {
+	if scheme != "https" {
+		return nil, fmt.Errorf("OTLP/gRPC needs an https endpoint, or httpcheck built with Go 1.24 or later")
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.ForceAttemptHTTP2 = true
+
+	return t, nil
+}
@@ -0,0 +1,619 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PostmanCollection is a Postman v2.1 collection.
+type PostmanCollection struct {
+	Info struct {
+		Name   string `json:"name"`
+		Schema string `json:"schema"`
+	} `json:"info"`
+	Item     []PostmanItem     `json:"item"`
+	Auth     *PostmanAuth      `json:"auth"`
+	Variable []PostmanVariable `json:"variable"`
+
+	dir string
+}
+
+// PostmanItem is a request or a folder of requests.
+type PostmanItem struct {
+	Name    string          `json:"name"`
+	Request *PostmanRequest `json:"request"`
+	Item    []PostmanItem   `json:"item"`
+	Auth    *PostmanAuth    `json:"auth"`
+}
+
+// PostmanRequest is the request of an item.
+type PostmanRequest struct {
+	Method string       `json:"method"`
+	URL    PostmanURL   `json:"url"`
+	Header []PostmanKV  `json:"header"`
+	Body   *PostmanBody `json:"body"`
+	Auth   *PostmanAuth `json:"auth"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler: a request can be a URL string.
+func (r *PostmanRequest) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		r.Method = http.MethodGet
+		r.URL.Raw = s
+		return nil
+	}
+
+	type request PostmanRequest
+	return json.Unmarshal(b, (*request)(r))
+}
+
+// PostmanURL is the URL of a request.
+type PostmanURL struct {
+	Raw      string      `json:"raw"`
+	Variable []PostmanKV `json:"variable"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler: a URL can be a string.
+func (u *PostmanURL) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		u.Raw = s
+		return nil
+	}
+
+	type postmanURL PostmanURL
+	return json.Unmarshal(b, (*postmanURL)(u))
+}
+
+// PostmanKV is a header, a query parameter, a form field or a variable.
+type PostmanKV struct {
+	Key         string `json:"key"`
+	Value       string `json:"value"`
+	Disabled    bool   `json:"disabled"`
+	Type        string `json:"type"`
+	Src         any    `json:"src"`
+	ContentType string `json:"contentType"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler: a value can be a bool or a
+// number, as the useBrowser param of an oauth2 auth.
+func (kv *PostmanKV) UnmarshalJSON(b []byte) error {
+	type postmanKV PostmanKV
+	v := struct {
+		*postmanKV
+		Value any `json:"value"`
+	}{postmanKV: (*postmanKV)(kv)}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	kv.Value = postmanString(v.Value)
+
+	return nil
+}
+
+// PostmanBody is the body of a request.
+type PostmanBody struct {
+	Mode       string      `json:"mode"`
+	Raw        string      `json:"raw"`
+	URLEncoded []PostmanKV `json:"urlencoded"`
+	FormData   []PostmanKV `json:"formdata"`
+	File       *struct {
+		Src string `json:"src"`
+	} `json:"file"`
+	GraphQL *struct {
+		Query     string `json:"query"`
+		Variables string `json:"variables"`
+	} `json:"graphql"`
+	Options *struct {
+		Raw *struct {
+			Language string `json:"language"`
+		} `json:"raw"`
+	} `json:"options"`
+	Disabled bool `json:"disabled"`
+}
+
+// PostmanAuth is the authentication of a request, a folder or a collection.
+type PostmanAuth struct {
+	Type   string                 `json:"type"`
+	Params map[string][]PostmanKV `json:"-"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler: the parameters are in a field
+// named after the type.
+func (a *PostmanAuth) UnmarshalJSON(b []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(fields["type"], &a.Type); err != nil {
+		return fmt.Errorf("invalid auth type: %w", err)
+	}
+	a.Params = map[string][]PostmanKV{}
+	if params, ok := fields[a.Type]; ok {
+		var kvs []PostmanKV
+		if err := json.Unmarshal(params, &kvs); err != nil {
+			return fmt.Errorf("invalid %s auth: %w", a.Type, err)
+		}
+		a.Params[a.Type] = kvs
+	}
+
+	return nil
+}
+
+func (a *PostmanAuth) param(key string) string {
+	for _, kv := range a.Params[a.Type] {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+
+	return ""
+}
+
+// PostmanVariable is a collection or environment variable.
+type PostmanVariable struct {
+	Key      string `json:"key"`
+	Value    any    `json:"value"`
+	Disabled bool   `json:"disabled"`
+	Enabled  *bool  `json:"enabled"`
+}
+
+// PostmanEnvironment is a Postman environment.
+type PostmanEnvironment struct {
+	Name   string            `json:"name"`
+	Values []PostmanVariable `json:"values"`
+}
+
+// PostmanRequestItem is a request of a collection, with the authentication
+// it inherits from its folders.
+type PostmanRequestItem struct {
+	Name    string
+	Request *PostmanRequest
+	Auth    *PostmanAuth
+}
+
+// LoadPostmanCollection reads the Postman v2.1 collection at path.
+func LoadPostmanCollection(path string) (*PostmanCollection, error) {
+	b, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	c := &PostmanCollection{dir: filepath.Dir(path)}
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, fmt.Errorf("invalid postman collection '%s': %w", path, err)
+	}
+	if c.Info.Schema != "" && !strings.Contains(c.Info.Schema, "v2.1") && !strings.Contains(c.Info.Schema, "v2.0") {
+		return nil, fmt.Errorf("unsupported postman collection schema '%s', export the collection as v2.1", c.Info.Schema)
+	}
+
+	return c, nil
+}
+
+// LoadPostmanEnvironment reads the Postman environment at path.
+func LoadPostmanEnvironment(path string) (*PostmanEnvironment, error) {
+	b, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	e := &PostmanEnvironment{}
+	if err := json.Unmarshal(b, e); err != nil {
+		return nil, fmt.Errorf("invalid postman environment '%s': %w", path, err)
+	}
+
+	return e, nil
+}
+
+// Requests returns the requests of the collection in order, named after
+// their folders, like "folder / request".
+func (c *PostmanCollection) Requests() []PostmanRequestItem {
+	var requests []PostmanRequestItem
+	var walk func(items []PostmanItem, prefix string, auth *PostmanAuth)
+	walk = func(items []PostmanItem, prefix string, auth *PostmanAuth) {
+		for _, item := range items {
+			itemAuth := auth
+			if item.Auth != nil {
+				itemAuth = item.Auth
+			}
+			if item.Request == nil {
+				walk(item.Item, prefix+item.Name+" / ", itemAuth)
+				continue
+			}
+			if item.Request.Auth != nil {
+				itemAuth = item.Request.Auth
+			}
+			requests = append(requests, PostmanRequestItem{Name: prefix + item.Name, Request: item.Request, Auth: itemAuth})
+		}
+	}
+	walk(c.Item, "", c.Auth)
+
+	return requests
+}
+
+// postmanString returns a JSON value as the string Postman substitutes.
+func postmanString(v any) string {
+	switch value := v.(type) {
+	case string:
+		return value
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// Variables returns the collection variables overridden by the environment.
+func (c *PostmanCollection) Variables(env *PostmanEnvironment) map[string]string {
+	vars := map[string]string{}
+	add := func(values []PostmanVariable) {
+		for _, v := range values {
+			if v.Disabled || (v.Enabled != nil && !*v.Enabled) {
+				continue
+			}
+			vars[v.Key] = postmanString(v.Value)
+		}
+	}
+	add(c.Variable)
+	if env != nil {
+		add(env.Values)
+	}
+
+	return vars
+}
+
+// Options returns the options of req, based on base. Like Postman, the
+// request is sent with "Accept: */*" and only has a Content-Type with a
+// body.
+func (c *PostmanCollection) Options(item PostmanRequestItem, base *Options, variables map[string]string) (*Options, error) {
+	r := &httpFileResolver{variables: variables}
+	req := item.Request
+	o := base.clone()
+
+	o.Method = strings.ToUpper(req.Method)
+	if o.Method == "" {
+		o.Method = http.MethodGet
+	}
+	rawURL, err := r.resolve(req.URL.Raw)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "http://" + rawURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url '%s': %w", rawURL, err)
+	}
+	// path variables, like :id.
+	if len(req.URL.Variable) > 0 {
+		segments := strings.Split(u.Path, "/")
+		for i, segment := range segments {
+			for _, v := range req.URL.Variable {
+				if segment == ":"+v.Key {
+					value, err := r.resolve(v.Value)
+					if err != nil {
+						return nil, err
+					}
+					segments[i] = url.PathEscape(value)
+				}
+			}
+		}
+		u.RawPath = ""
+		u.Path, err = url.PathUnescape(strings.Join(segments, "/"))
+		if err != nil {
+			return nil, err
+		}
+	}
+	o.URL = u.String()
+
+	seen := map[string]bool{}
+	for _, h := range req.Header {
+		if h.Disabled {
+			continue
+		}
+		name, err := r.resolve(h.Key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := r.resolve(h.Value)
+		if err != nil {
+			return nil, err
+		}
+		if name = http.CanonicalHeaderKey(name); !seen[name] {
+			o.Header.Del(name)
+			seen[name] = true
+		}
+		o.Header.Add(name, value)
+	}
+
+	if err := c.body(req.Body, o, r); err != nil {
+		return nil, err
+	}
+	if err := postmanAuth(item.Auth, o, r); err != nil {
+		return nil, err
+	}
+
+	if _, ok := o.Header[acceptHeader]; !ok {
+		o.Header.Set(acceptHeader, "*/*")
+	}
+	if _, ok := o.Header[contentTypeHeader]; !ok && o.RawBody == nil && !o.IsForm && !o.isMultipart() {
+		o.Header[contentTypeHeader] = nil
+	}
+
+	return o, nil
+}
+
+func (c *PostmanCollection) body(body *PostmanBody, o *Options, r *httpFileResolver) error {
+	if body == nil || body.Disabled {
+		return nil
+	}
+
+	switch body.Mode {
+	case "raw":
+		if body.Raw == "" {
+			return nil
+		}
+		raw, err := r.resolve(body.Raw)
+		if err != nil {
+			return err
+		}
+		o.RawBody = []byte(raw)
+		language := ""
+		if body.Options != nil && body.Options.Raw != nil {
+			language = body.Options.Raw.Language
+		}
+		switch language {
+		case "json":
+			o.ContentType = contentTypeJSON
+		case "xml":
+			o.ContentType = "application/xml"
+		case "html":
+			o.ContentType = "text/html"
+		case "javascript":
+			o.ContentType = "application/javascript"
+		default:
+			o.ContentType = "text/plain"
+		}
+	case "urlencoded":
+		o.IsForm = true
+		for _, kv := range body.URLEncoded {
+			if kv.Disabled {
+				continue
+			}
+			key, err := r.resolve(kv.Key)
+			if err != nil {
+				return err
+			}
+			value, err := r.resolve(kv.Value)
+			if err != nil {
+				return err
+			}
+			o.FormData.Add(key, value)
+		}
+		o.ContentType = "application/x-www-form-urlencoded"
+	case "formdata":
+		o.IsMultipart = true
+		for _, kv := range body.FormData {
+			if kv.Disabled {
+				continue
+			}
+			key, err := r.resolve(kv.Key)
+			if err != nil {
+				return err
+			}
+			if kv.Type != "file" {
+				value, err := r.resolve(kv.Value)
+				if err != nil {
+					return err
+				}
+				o.FormData.Add(key, value)
+				continue
+			}
+			srcs, ok := kv.Src.([]any)
+			if !ok {
+				srcs = []any{kv.Src}
+			}
+			for _, src := range srcs {
+				path, _ := src.(string)
+				if path == "" {
+					continue
+				}
+				f, err := parseFormFile(key, c.path(path))
+				if err != nil {
+					return err
+				}
+				if kv.ContentType != "" {
+					f.ContentType = kv.ContentType
+				}
+				o.Files = append(o.Files, f)
+			}
+		}
+	case "file":
+		if body.File == nil || body.File.Src == "" {
+			return nil
+		}
+		b, err := os.ReadFile(filepath.Clean(c.path(body.File.Src)))
+		if err != nil {
+			return fmt.Errorf("cannot read '%s': %w", body.File.Src, err)
+		}
+		o.RawBody = b
+		o.ContentType = "application/octet-stream"
+	case "graphql":
+		if body.GraphQL == nil {
+			return nil
+		}
+		query, err := r.resolve(body.GraphQL.Query)
+		if err != nil {
+			return err
+		}
+		payload := map[string]any{"query": query}
+		if vars := strings.TrimSpace(body.GraphQL.Variables); vars != "" {
+			if vars, err = r.resolve(vars); err != nil {
+				return err
+			}
+			var v any
+			if err := json.Unmarshal([]byte(vars), &v); err != nil {
+				return fmt.Errorf("invalid graphql variables: %w", err)
+			}
+			payload["variables"] = v
+		}
+		b, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		o.RawBody = b
+		o.ContentType = contentTypeJSON
+	case "":
+	default:
+		return fmt.Errorf("unsupported body mode '%s'", body.Mode)
+	}
+
+	return nil
+}
+
+// path resolves a path relative to the collection.
+func (c *PostmanCollection) path(path string) string {
+	if filepath.IsAbs(path) || c.dir == "" {
+		return path
+	}
+
+	return filepath.Join(c.dir, path)
+}
+
+func postmanAuth(auth *PostmanAuth, o *Options, r *httpFileResolver) error {
+	if auth == nil {
+		return nil
+	}
+	param := func(key string) (string, error) {
+		return r.resolve(auth.param(key))
+	}
+
+	switch auth.Type {
+	case "noauth":
+	case "basic", "digest":
+		username, err := param("username")
+		if err != nil {
+			return err
+		}
+		password, err := param("password")
+		if err != nil {
+			return err
+		}
+		o.Auth = username + ":" + password
+		o.AuthType = auth.Type
+	case "bearer":
+		token, err := param("token")
+		if err != nil {
+			return err
+		}
+		o.Auth = token
+		o.AuthType = authTypeBearer
+	case "oauth2":
+		// the token Postman got interactively.
+		token, err := param("accessToken")
+		if err != nil {
+			return err
+		}
+		if token == "" {
+			return fmt.Errorf("oauth2 auth without an access token is not supported")
+		}
+		o.Auth = token
+		o.AuthType = authTypeBearer
+	case "apikey":
+		key, err := param("key")
+		if err != nil {
+			return err
+		}
+		value, err := param("value")
+		if err != nil {
+			return err
+		}
+		if auth.param("in") == "query" {
+			o.QueryParams.Add(key, value)
+		} else {
+			o.Header.Set(key, value)
+		}
+	case "awsv4":
+		var values [5]string
+		for i, key := range []string{"accessKey", "secretKey", "sessionToken", "service", "region"} {
+			v, err := param(key)
+			if err != nil {
+				return err
+			}
+			values[i] = v
+		}
+		signer, err := NewSigV4Signer(values[3]+":"+values[4], AWSCredentials{
+			AccessKeyID:     values[0],
+			SecretAccessKey: values[1],
+			SessionToken:    values[2],
+		})
+		if err != nil {
+			return err
+		}
+		o.Signers = append(o.Signers, signer)
+	default:
+		return fmt.Errorf("unsupported auth type '%s'", auth.Type)
+	}
+
+	return nil
+}
+
+// Select returns the requests matching names, either the name of a request
+// or a folder, or its position starting at 1, or all the requests if there
+// are no names.
+func (c *PostmanCollection) Select(names []string) ([]PostmanRequestItem, error) {
+	requests := c.Requests()
+	if len(names) == 0 {
+		return requests, nil
+	}
+
+	var selected []PostmanRequestItem
+	for _, name := range names {
+		found := false
+		for i, req := range requests {
+			if req.Name == name || strings.HasPrefix(req.Name, name+" / ") || fmt.Sprint(i+1) == name {
+				selected = append(selected, req)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no request or folder named '%s'", name)
+		}
+	}
+
+	return selected, nil
+}
+
+// Run traces the requests one after the other, sharing their cookies, and
+// returns a report entry for each.
+func (c *PostmanCollection) Run(ctx context.Context, requests []PostmanRequestItem, base *Options, variables map[string]string) ([]ReportEntry, error) {
+	base = base.clone()
+	if base.Jar == nil {
+		jar, err := newSessionJar()
+		if err != nil {
+			return nil, err
+		}
+		base.Jar = jar
+	}
+
+	entries := make([]ReportEntry, 0, len(requests))
+	for _, req := range requests {
+		e := ReportEntry{Name: req.Name}
+		opts, err := c.Options(req, base, variables)
+		if err == nil {
+			if err = validateAuth(opts); err == nil {
+				e.Result, err = Trace(ctx, opts)
+			}
+		}
+		e.Err = err
+		entries = append(entries, e)
+	}
+
+	return entries, nil
+}
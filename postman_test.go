@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostmanCollection_Requests(t *testing.T) {
+	c, err := LoadPostmanCollection("testdata/collection.postman_collection.json")
+	require.NoError(t, err)
+
+	var names, auths []string
+	for _, req := range c.Requests() {
+		names = append(names, req.Name)
+		auths = append(auths, req.Auth.Type)
+	}
+	assert.Equal(t, []string{"list", "admin / create", "admin / update", "health"}, names)
+	assert.Equal(t, []string{"bearer", "basic", "noauth", "bearer"}, auths)
+
+	env, err := LoadPostmanEnvironment("testdata/staging.postman_environment.json")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"host": "localhost:1", "token": "staging", "password": "s3cret"}, c.Variables(env))
+
+	requests, err := c.Select([]string{"admin", "4"})
+	require.NoError(t, err)
+	assert.Len(t, requests, 3)
+
+	_, err = c.Select([]string{"delete"})
+	assert.EqualError(t, err, "no request or folder named 'delete'")
+}
+
+func TestPostmanCollection_Options(t *testing.T) {
+	c, err := LoadPostmanCollection("testdata/collection.postman_collection.json")
+	require.NoError(t, err)
+	env, err := LoadPostmanEnvironment("testdata/staging.postman_environment.json")
+	require.NoError(t, err)
+	vars := c.Variables(env)
+	requests := c.Requests()
+
+	opts, err := c.Options(requests[0], NewDefaultOptions(), vars)
+	require.NoError(t, err)
+	assert.Equal(t, "GET", opts.Method)
+	assert.Equal(t, "http://localhost:1/orders?size=10", opts.URL)
+	assert.Equal(t, "1", opts.Header.Get("X-Page"))
+	assert.NotContains(t, opts.Header, "X-Disabled")
+	assert.Equal(t, "*/*", opts.Header.Get("Accept"))
+	assert.Nil(t, opts.Header["Content-Type"])
+	assert.Equal(t, authTypeBearer, opts.AuthType)
+	assert.Equal(t, "staging", opts.Auth)
+
+	opts, err = c.Options(requests[1], NewDefaultOptions(), vars)
+	require.NoError(t, err)
+	assert.Equal(t, `{"item": "book"}`, string(opts.RawBody))
+	assert.Equal(t, contentTypeJSON, opts.ContentType)
+	assert.Equal(t, authTypeBasic, opts.AuthType)
+	assert.Equal(t, "admin:s3cret", opts.Auth)
+
+	opts, err = c.Options(requests[2], NewDefaultOptions(), vars)
+	require.NoError(t, err)
+	assert.Equal(t, "http://localhost:1/orders/42", opts.URL)
+	assert.True(t, opts.IsForm)
+	assert.Equal(t, "item=pen", opts.FormData.Encode())
+	assert.Empty(t, opts.Auth)
+
+	_, err = c.Options(requests[1], NewDefaultOptions(), c.Variables(nil))
+	assert.EqualError(t, err, "unknown variable 'password'")
+}
+
+func TestPostmanCollection_nonStringValues(t *testing.T) {
+	c, err := LoadPostmanCollection("testdata/oauth2.postman_collection.json")
+	require.NoError(t, err)
+	requests := c.Requests()
+	require.Len(t, requests, 1)
+	assert.Equal(t, "false", requests[0].Auth.param("useBrowser"))
+	assert.Empty(t, requests[0].Auth.param("tokenType"))
+
+	opts, err := c.Options(requests[0], NewDefaultOptions(), c.Variables(nil))
+	require.NoError(t, err)
+	assert.Equal(t, "3", opts.Header.Get("X-Retries"))
+	assert.Equal(t, authTypeBearer, opts.AuthType)
+	assert.Equal(t, "abc", opts.Auth)
+}
+
+func TestPostmanCollection_Run(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/orders":
+			if req.Method == http.MethodPost {
+				user, password, ok := req.BasicAuth()
+				assert.True(t, ok)
+				assert.Equal(t, "admin:s3cret", user+":"+password)
+				b, err := io.ReadAll(req.Body)
+				require.NoError(t, err)
+				assert.Equal(t, `{"item": "book"}`, string(b))
+				http.SetCookie(rw, &http.Cookie{Name: "sid", Value: "1"})
+				return
+			}
+			assert.Equal(t, "Bearer staging", req.Header.Get("Authorization"))
+		case "/orders/42":
+			_, err := req.Cookie("sid")
+			assert.NoError(t, err)
+			assert.Equal(t, "application/x-www-form-urlencoded", req.Header.Get("Content-Type"))
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer svr.Close()
+
+	c, err := LoadPostmanCollection("testdata/collection.postman_collection.json")
+	require.NoError(t, err)
+	env, err := LoadPostmanEnvironment("testdata/staging.postman_environment.json")
+	require.NoError(t, err)
+	vars := c.Variables(env)
+	vars["host"] = svr.URL
+
+	entries, err := c.Run(context.Background(), c.Requests(), NewDefaultOptions(), vars)
+	require.NoError(t, err)
+
+	require.Len(t, entries, 4)
+	for _, e := range entries {
+		require.NoError(t, e.Err, e.Name)
+	}
+	assert.Equal(t, []string{"200", "200", "200", "404"}, []string{entries[0].Result.Status, entries[1].Result.Status, entries[2].Result.Status, entries[3].Result.Status})
+}
+
+func TestPostmanAuth_unsupported(t *testing.T) {
+	err := postmanAuth(&PostmanAuth{Type: "ntlm"}, NewDefaultOptions(), &httpFileResolver{})
+	assert.EqualError(t, err, "unsupported auth type 'ntlm'")
+}
@@ -9,7 +9,17 @@ import (
 	"text/template"
 )
 
-const tpl = `Connected to {{ cyan .RemoteAddr }} from {{ .LocalAddr }}
+const tpl = `
+{{- range .Hops -}}
+{{ green .Name }} {{ cyan .Result.Status }} in {{ .Result.Total | fmtms | cyan }} {{ hopPhases .Result | gray }}
+{{ end }}
+{{- if .Hops }}
+{{ end }}
+{{- if .UnixSocket -}}
+Connected to {{ cyan .UnixSocket }} (unix socket)
+{{- else -}}
+Connected to {{ cyan .RemoteAddr }} from {{ .LocalAddr }}
+{{- end }}
 
 {{ green .HTTPVersion }} {{ cyan .Status }}
 {{ range $header := .Headers }}
@@ -28,7 +38,10 @@ const tpl = `Connected to {{ cyan .RemoteAddr }} from {{ .LocalAddr }}
 {{ green "Body" }} stored in: {{ .Output }}
 {{- end }}
 
-{{- if .IsHTTPS }}
+{{- if .Phases }}
+
+{{ diagram .Phases }}
+{{ else if .IsHTTPS }}
 
   DNS Lookup   TCP Connection   TLS Handshake   Server Processing   Content Transfer
 [{{fmta .DNSLookup | cyan}}   | {{fmta .TCPConnection | cyan}}      | {{fmta .TLSHandshake | cyan}}     |   {{fmta .ServerProcessing | cyan}}       |  {{fmta .ContentTransfer | cyan}}       ]
@@ -48,6 +61,14 @@ const tpl = `Connected to {{ cyan .RemoteAddr }} from {{ .LocalAddr }}
                                       starttransfer:{{fmtb .StartTransfer | cyan}}        |
                                                                  total:{{fmtb .Total | cyan}}
 {{ end }}
+{{- with .CORS }}
+CORS preflight for {{ .Request.Method }} from {{ .Request.Origin }}: {{ if .Allowed }}{{ green "allowed" }}{{ else }}{{ red "denied" }}{{ end }}
+{{- range .Checks }}
+  {{ if .OK }}{{ green "ok  " }}{{ else }}{{ red "fail" }}{{ end }} {{ printf "%-8s" .Name }} {{ .Detail }}
+{{- end }}
+  credentials: {{ .AllowCredentials }}
+  {{- with .MaxAge }}, max-age: {{ . }}{{ end }}
+{{ end }}
 `
 
 func fmta(d int64) string {
@@ -58,6 +79,99 @@ func fmtb(d int64) string {
 	return fmt.Sprintf("%-9s", strconv.Itoa(int(d))+"ms")
 }
 
+// phase is a single column of the timing diagram.
+type phase struct {
+	Name     string
+	Label    string
+	Duration int64
+}
+
+// diagram renders phases in the same layout as the built-in templates: one
+// column per phase, followed by the cumulative time at the end of each phase.
+func diagram(phases []phase, color func(string) string) string {
+	var header, cells, pipes strings.Builder
+	boundaries := make([]int, len(phases))
+	pos := 0
+	for i, p := range phases {
+		width := len(p.Name) + 2
+		value := fmta(p.Duration)
+		left := max(width-len(value), 0) / 2
+		right := max(width-len(value)-left, 0)
+
+		sep := "|"
+		if i == 0 {
+			sep = "["
+		}
+		header.WriteString("  " + p.Name + " ")
+		cells.WriteString(sep + strings.Repeat(" ", left) + color(value) + strings.Repeat(" ", right))
+		pipes.WriteString(strings.Repeat(" ", width) + "|")
+
+		pos += 1 + width
+		boundaries[i] = pos
+	}
+	cells.WriteString("]")
+
+	lines := []string{
+		strings.TrimRight(header.String(), " "),
+		cells.String(),
+		" " + pipes.String(),
+	}
+	var cumulative int64
+	for i, p := range phases {
+		cumulative += p.Duration
+		label := p.Label + ":"
+		line := strings.Repeat(" ", max(boundaries[i]+2-len(label), 0)) + label
+		n := len(line) + len(fmtb(cumulative))
+		line += color(fmtb(cumulative))
+		for _, b := range boundaries[i+1:] {
+			pad := max(b-n, 1)
+			line += strings.Repeat(" ", pad) + "|"
+			n += pad + 1
+		}
+		lines = append(lines, line)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// phases returns the timing diagram columns of r.
+func phases(r *Result, isHTTPS bool) []phase {
+	var p []phase
+	if r.UnixSocket != "" {
+		p = append(p, phase{Name: "Socket Connect", Label: "connect", Duration: r.MetricSocketConnect})
+	} else {
+		p = append(p,
+			phase{Name: "DNS Lookup", Label: "namelookup", Duration: r.MetricDNSLookup},
+			phase{Name: "TCP Connection", Label: "connect", Duration: r.MetricTCPConnection},
+		)
+	}
+	if isHTTPS {
+		p = append(p, phase{Name: "TLS Handshake", Label: "pretransfer", Duration: r.MetricTLSHandshake})
+	}
+	if r.RequestBodySize != 0 {
+		p = append(p, phase{Name: "Request Upload", Label: "upload", Duration: r.MetricRequestUpload})
+	}
+
+	return append(p,
+		phase{Name: "Server Processing", Label: "starttransfer", Duration: r.MetricServerProcessing},
+		phase{Name: "Content Transfer", Label: "total", Duration: r.MetricContentTransfer},
+	)
+}
+
+func fmtms(d int64) string {
+	return strconv.Itoa(int(d)) + "ms"
+}
+
+// hopPhases renders the phases of a hop on a single line.
+func hopPhases(r *Result) string {
+	var parts []string
+	for _, p := range phases(r, strings.HasPrefix(r.URL, "https://")) {
+		parts = append(parts, p.Name+" "+fmtms(p.Duration))
+	}
+
+	return "(" + strings.Join(parts, ", ") + ")"
+}
+
 func cyan(s string) string {
 	return fmt.Sprintf("\033[36m%s\033[0m", s)
 }
@@ -66,6 +180,10 @@ func gray(s string) string {
 	return fmt.Sprintf("\033[38;5;245m%s\033[0m", s)
 }
 
+func red(s string) string {
+	return fmt.Sprintf("\033[31m%s\033[0m", s)
+}
+
 func green(s string) string {
 	return fmt.Sprintf("\033[32m%s\033[0m", s)
 }
@@ -77,6 +195,7 @@ func noColor(s string) string {
 type printOptions struct {
 	showBody    bool
 	maxBodySize int
+	cors        *CORSDecision
 	out         io.Writer
 	color       bool
 }
@@ -99,6 +218,13 @@ func WithMaxBodySize(n int) PrintOption {
 	}
 }
 
+// WithCORS configures PrintResult to summarize a CORS preflight decision.
+func WithCORS(d *CORSDecision) PrintOption {
+	return func(opts *printOptions) {
+		opts.cors = d
+	}
+}
+
 // WithOut configures PrintResult to write the result to the provided destination.
 func WithOut(w io.Writer) PrintOption {
 	return func(opts *printOptions) {
@@ -114,6 +240,7 @@ func WithNoColor() PrintOption {
 }
 
 type data struct {
+	UnixSocket  string
 	RemoteAddr  string
 	LocalAddr   string
 	HTTPVersion string
@@ -128,6 +255,12 @@ type data struct {
 	ShowBody    bool
 	IsHTTPS     bool
 
+	// Phases replaces the built-in timing diagrams when set.
+	Phases []phase
+
+	CORS *CORSDecision
+	Hops []Hop
+
 	DNSLookup        int64
 	TCPConnection    int64
 	TLSHandshake     int64
@@ -171,6 +304,7 @@ func PrintResult(r *Result, opts ...PrintOption) error {
 	total := startTransfer + r.MetricContentTransfer
 
 	d := data{
+		UnixSocket:  r.UnixSocket,
 		RemoteAddr:  r.RemoteAddr,
 		LocalAddr:   r.LocalAddr,
 		HTTPVersion: r.HTTPVersion,
@@ -182,6 +316,8 @@ func PrintResult(r *Result, opts ...PrintOption) error {
 		BodySize:    bodySize,
 		BodyMaxSize: options.maxBodySize,
 		ShowBody:    options.showBody,
+		CORS:        options.cors,
+		Hops:        r.Hops,
 		IsHTTPS:     strings.HasPrefix(r.URL, "https://"),
 
 		DNSLookup:        r.MetricDNSLookup,
@@ -196,20 +332,30 @@ func PrintResult(r *Result, opts ...PrintOption) error {
 		Total:         total,
 	}
 
+	if r.UnixSocket != "" || r.RequestBodySize != 0 {
+		d.Phases = phases(r, d.IsHTTPS)
+	}
+
 	funcs := template.FuncMap{
-		"join":  strings.Join,
-		"fmta":  fmta,
-		"fmtb":  fmtb,
-		"cyan":  cyan,
-		"gray":  gray,
-		"green": green,
+		"join":      strings.Join,
+		"fmta":      fmta,
+		"fmtb":      fmtb,
+		"fmtms":     fmtms,
+		"hopPhases": hopPhases,
+		"cyan":      cyan,
+		"gray":      gray,
+		"green":     green,
+		"red":       red,
 	}
 	if !options.color {
-		colors := []string{"cyan", "gray", "green"}
+		colors := []string{"cyan", "gray", "green", "red"}
 		for _, color := range colors {
 			funcs[color] = noColor
 		}
 	}
+	funcs["diagram"] = func(phases []phase) string {
+		return diagram(phases, funcs["cyan"].(func(string) string))
+	}
 	tmpl, err := template.New("result").Funcs(funcs).Parse(tpl)
 	if err != nil {
 		return err
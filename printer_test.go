@@ -108,6 +108,99 @@ func TestPrintResult_success(t *testing.T) {
 				MetricContentTransfer:  10,
 			},
 		},
+		{
+			name: "unix",
+			result: &Result{
+				URL:         "http://localhost/info",
+				UnixSocket:  "/var/run/docker.sock",
+				RemoteAddr:  "/var/run/docker.sock",
+				HTTPVersion: "HTTP/1.1",
+				Status:      "200",
+				Headers: []Header{
+					{Name: "Server", Value: "test"},
+				},
+				Output:                 "testdata/response_body.txt",
+				MetricSocketConnect:    10,
+				MetricServerProcessing: 10,
+				MetricContentTransfer:  10,
+			},
+		},
+		{
+			name: "upload",
+			result: &Result{
+				URL:         "https://1.1.1.1",
+				RemoteAddr:  "1.1.1.1:443",
+				LocalAddr:   "192.168.1.1:63917",
+				HTTPVersion: "HTTP/2.0",
+				Status:      "201",
+				Headers: []Header{
+					{Name: "Server", Value: "test"},
+				},
+				Output:                 "testdata/response_body.txt",
+				RequestBodySize:        2048,
+				MetricDNSLookup:        10,
+				MetricTCPConnection:    10,
+				MetricTLSHandshake:     10,
+				MetricRequestUpload:    10,
+				MetricServerProcessing: 10,
+				MetricContentTransfer:  10,
+			},
+		},
+		{
+			name: "cors",
+			opts: []PrintOption{WithCORS(&CORSDecision{
+				Request: &CORSRequest{Origin: "https://app.example.com", Method: "PUT", Headers: []string{"x-token"}},
+				Checks: []CORSCheck{
+					{Name: "status", Detail: "204", OK: true},
+					{Name: "origin", Detail: "allowed by Access-Control-Allow-Origin: *", OK: true},
+					{Name: "method", Detail: "PUT is allowed", OK: true},
+					{Name: "headers", Detail: "x-token not in Access-Control-Allow-Headers"},
+				},
+				MaxAge: "600",
+			})},
+			result: &Result{
+				URL:         "http://1.1.1.1",
+				RemoteAddr:  "1.1.1.1:80",
+				LocalAddr:   "192.168.1.1:63917",
+				HTTPVersion: "HTTP/1.1",
+				Status:      "204",
+				Headers: []Header{
+					{Name: "Access-Control-Allow-Methods", Value: "PUT"},
+					{Name: "Access-Control-Allow-Origin", Value: "*"},
+					{Name: "Access-Control-Max-Age", Value: "600"},
+				},
+				Output:                 "testdata/response_body.txt",
+				MetricDNSLookup:        10,
+				MetricTCPConnection:    10,
+				MetricServerProcessing: 10,
+				MetricContentTransfer:  10,
+			},
+		},
+		{
+			name: "hops",
+			result: &Result{
+				URL:         "http://1.1.1.1",
+				RemoteAddr:  "1.1.1.1:80",
+				LocalAddr:   "192.168.1.1:63917",
+				HTTPVersion: "HTTP/1.1",
+				Status:      "200",
+				Headers: []Header{
+					{Name: "Server", Value: "test"},
+				},
+				Output:                 "testdata/response_body.txt",
+				MetricServerProcessing: 10,
+				MetricContentTransfer:  10,
+				Hops: []Hop{
+					{Name: "digest challenge", Result: &Result{
+						URL:                    "http://1.1.1.1",
+						Status:                 "401",
+						MetricDNSLookup:        10,
+						MetricTCPConnection:    10,
+						MetricServerProcessing: 10,
+					}},
+				},
+			},
+		},
 	}
 
 	for _, tc := range cases {
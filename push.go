@@ -0,0 +1,391 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// pushTimeout is how long pushing the metrics of a run may take.
+	pushTimeout = 10 * time.Second
+
+	defaultStatsDPort = "8125"
+	defaultInfluxPath = "/api/v2/write"
+	defaultPushJob    = "httpcheck"
+)
+
+// pushSchemes are the schemes of the --push URLs.
+var pushSchemes = []string{"statsd", "influx", "influxs", "pushgateway", "pushgateways"}
+
+// Pusher pushes the metrics of each traced request to sinks, with tags.
+type Pusher struct {
+	Sinks []PushSink
+	// Tags are the tags of the metrics, as key and value pairs. The target
+	// tag defaults to the URL of the request.
+	Tags []string
+}
+
+// PushSink is where the metrics of a run are pushed to.
+type PushSink interface {
+	Push(ctx context.Context, m PushMetrics) error
+}
+
+// PushMetrics are the metrics of a run.
+type PushMetrics struct {
+	Time time.Time
+	// Tags are key and value pairs.
+	Tags []string
+	// Phases are the durations of the phases of the request, in
+	// milliseconds, keyed by the names of the report columns, in order.
+	Phases []PushValue
+	// Values are the other metrics: status, body size, success and the
+	// expiry of the certificate, if any.
+	Values []PushValue
+}
+
+// PushValue is a named metric.
+type PushValue struct {
+	Name  string
+	Value int64
+}
+
+// NewPusher returns a pusher to the sinks of urls, like
+// "statsd://localhost:8125", "influx://localhost:8086/api/v2/write?bucket=checks"
+// or "pushgateway://localhost:9091", with tags given as "key=value".
+func NewPusher(urls, tags []string) (*Pusher, error) {
+	p := &Pusher{}
+	for _, t := range tags {
+		key, value, ok := strings.Cut(t, "=")
+		if !ok || !isPushTag(key) {
+			return nil, fmt.Errorf("invalid push tag '%s', use key=value with a key of letters, digits and _", t)
+		}
+		p.Tags = append(p.Tags, key, value)
+	}
+	for _, raw := range urls {
+		sink, err := newPushSink(raw)
+		if err != nil {
+			return nil, err
+		}
+		p.Sinks = append(p.Sinks, sink)
+	}
+
+	return p, nil
+}
+
+// isPushTag reports whether key is a valid tag key for every sink.
+func isPushTag(key string) bool {
+	if key == "" || (key[0] >= '0' && key[0] <= '9') {
+		return false
+	}
+	for _, c := range key {
+		if c != '_' && (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			return false
+		}
+	}
+
+	return true
+}
+
+func newPushSink(raw string) (PushSink, error) {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid push URL '%s', use one of the schemes: %s", raw, strings.Join(pushSchemes, ", "))
+	}
+	q := u.Query()
+	switch u.Scheme {
+	case "statsd":
+		addr := u.Host
+		if u.Port() == "" {
+			addr = net.JoinHostPort(u.Hostname(), defaultStatsDPort)
+		}
+		prefix := defaultPushJob
+		if q.Has("prefix") {
+			prefix = q.Get("prefix")
+		}
+		return &StatsDSink{Addr: addr, Prefix: prefix}, nil
+	case "influx", "influxs":
+		token := q.Get("token")
+		q.Del("token")
+		endpoint := url.URL{Scheme: httpScheme(u.Scheme), Host: u.Host, Path: u.Path, RawQuery: q.Encode()}
+		if endpoint.Path == "" {
+			endpoint.Path = defaultInfluxPath
+		}
+		return &InfluxSink{URL: endpoint.String(), Token: token}, nil
+	case "pushgateway", "pushgateways":
+		job := q.Get("job")
+		if job == "" {
+			job = defaultPushJob
+		}
+		endpoint := url.URL{Scheme: httpScheme(u.Scheme), Host: u.Host, Path: strings.TrimSuffix(u.Path, "/")}
+		return &PushgatewaySink{URL: endpoint.String(), Job: job}, nil
+	}
+
+	return nil, fmt.Errorf("unknown push scheme '%s', must be one of: %s", u.Scheme, strings.Join(pushSchemes, ", "))
+}
+
+// httpScheme returns https for the schemes ending with "s", http otherwise.
+func httpScheme(scheme string) string {
+	if strings.HasSuffix(scheme, "s") {
+		return "https"
+	}
+
+	return "http"
+}
+
+// Push pushes the metrics of a traced request, r or err, to every sink.
+func (p *Pusher) Push(ctx context.Context, rawURL string, r *Result, err error) error {
+	// the request may be canceled, like at its timeout, but not its metrics.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), pushTimeout)
+	defer cancel()
+
+	m := NewPushMetrics(time.Now(), rawURL, p.Tags, r, err)
+	for _, sink := range p.Sinks {
+		if err := sink.Push(ctx, m); err != nil {
+			return fmt.Errorf("cannot push the metrics: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// NewPushMetrics returns the metrics of a run at t, r or err, with the tags
+// and a target tag of rawURL unless tags have one. The target is the URL
+// without its credentials, query or fragment, which can hold secrets and
+// vary from one run to the next.
+func NewPushMetrics(t time.Time, rawURL string, tags []string, r *Result, err error) PushMetrics {
+	m := PushMetrics{Time: t}
+	hasTarget := false
+	for i := 0; i+1 < len(tags); i += 2 {
+		hasTarget = hasTarget || tags[i] == "target"
+	}
+	if !hasTarget {
+		m.Tags = append(m.Tags, "target", pushTarget(rawURL))
+	}
+	m.Tags = append(m.Tags, tags...)
+
+	success := int64(0)
+	if probeSucceeded(r, err) {
+		success = 1
+	}
+	m.Values = append(m.Values, PushValue{"success", success})
+	if r == nil {
+		return m
+	}
+	for _, c := range reportColumns {
+		m.Phases = append(m.Phases, PushValue{strings.ToLower(c.name), c.metric(r)})
+	}
+	status, _ := strconv.Atoi(r.Status)
+	m.Values = append(m.Values, PushValue{"status", int64(status)}, PushValue{"body_size", r.BodySize})
+	if !r.CertExpiry.IsZero() {
+		m.Values = append(m.Values, PushValue{"cert_expiry", r.CertExpiry.Unix()})
+	}
+
+	return m
+}
+
+// pushTarget returns the scheme, host and path of rawURL.
+func pushTarget(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		// the request failed early, and its URL is kept up to the query.
+		target, _, _ := strings.Cut(rawURL, "?")
+		target, _, _ = strings.Cut(target, "#")
+		return target
+	}
+
+	return (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: u.Path, RawPath: u.RawPath}).String()
+}
+
+// StatsDSink sends the metrics to StatsD over UDP, the phases as timers and
+// the other metrics as gauges, with tags in the DogStatsD format.
+type StatsDSink struct {
+	Addr   string
+	Prefix string
+}
+
+// Push implements PushSink.
+func (s *StatsDSink) Push(ctx context.Context, m PushMetrics) error {
+	var tags []string
+	for i := 0; i+1 < len(m.Tags); i += 2 {
+		tags = append(tags, statsdEscaper.Replace(m.Tags[i])+":"+statsdEscaper.Replace(m.Tags[i+1]))
+	}
+	suffix := ""
+	if len(tags) > 0 {
+		suffix = "|#" + strings.Join(tags, ",")
+	}
+	name := func(metric string) string {
+		if s.Prefix == "" {
+			return metric
+		}
+		return s.Prefix + "." + metric
+	}
+
+	var lines []string
+	for _, v := range m.Phases {
+		lines = append(lines, fmt.Sprintf("%s:%d|ms%s", name(v.Name), v.Value, suffix))
+	}
+	for _, v := range m.Values {
+		lines = append(lines, fmt.Sprintf("%s:%d|g%s", name(v.Name), v.Value, suffix))
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", s.Addr)
+	if err != nil {
+		return err
+	}
+	defer close(conn)
+	// a datagram per metric, so that none is over the MTU.
+	for _, line := range lines {
+		if _, err := conn.Write([]byte(line)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// statsdEscaper removes the separators of the DogStatsD format from tags.
+var statsdEscaper = strings.NewReplacer("|", "_", ",", "_", "#", "_", "\n", " ")
+
+// InfluxSink writes the metrics to InfluxDB with the line protocol, as a
+// point of the httpcheck measurement.
+type InfluxSink struct {
+	// URL is the write endpoint, like /api/v2/write?bucket=checks&org=ops or
+	// /write?db=checks.
+	URL   string
+	Token string
+}
+
+// Push implements PushSink.
+func (s *InfluxSink) Push(ctx context.Context, m PushMetrics) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, strings.NewReader(InfluxLine(m)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set(contentTypeHeader, "text/plain; charset=utf-8")
+	if s.Token != "" {
+		req.Header.Set(authorizationHeader, "Token "+s.Token)
+	}
+
+	return pushRequest(req)
+}
+
+// influxEscaper escapes the tag keys and values of the line protocol.
+var influxEscaper = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `, "\n", `\n`)
+
+// InfluxLine returns m as a line of the line protocol, with fields in
+// milliseconds for the phases.
+func InfluxLine(m PushMetrics) string {
+	var b strings.Builder
+	b.WriteString(defaultPushJob)
+	for i := 0; i+1 < len(m.Tags); i += 2 {
+		if m.Tags[i+1] == "" {
+			// empty tag values are not allowed.
+			continue
+		}
+		b.WriteString("," + influxEscaper.Replace(m.Tags[i]) + "=" + influxEscaper.Replace(m.Tags[i+1]))
+	}
+	for i, v := range append(append([]PushValue{}, m.Phases...), m.Values...) {
+		sep := ","
+		if i == 0 {
+			sep = " "
+		}
+		b.WriteString(sep + v.Name + "=" + strconv.FormatInt(v.Value, 10) + "i")
+	}
+	b.WriteString(" " + strconv.FormatInt(m.Time.UnixNano(), 10) + "\n")
+
+	return b.String()
+}
+
+// PushgatewaySink pushes the metrics to a Prometheus Pushgateway, in the
+// group of the job and the tags.
+type PushgatewaySink struct {
+	URL string
+	Job string
+}
+
+// Push implements PushSink.
+func (s *PushgatewaySink) Push(ctx context.Context, m PushMetrics) error {
+	var body bytes.Buffer
+	if err := WritePushgatewayMetrics(&body, m); err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.URL+PushgatewayPath(s.Job, m.Tags), &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(contentTypeHeader, "text/plain; version=0.0.4")
+
+	return pushRequest(req)
+}
+
+// PushgatewayPath returns the path of the group of job and tags. Values
+// that are not safe in a path are base64 encoded.
+func PushgatewayPath(job string, tags []string) string {
+	segment := func(name, value string) string {
+		if value == "" || strings.ContainsAny(value, "/%?#") || url.PathEscape(value) != value {
+			return "/" + name + "@base64/" + base64.URLEncoding.EncodeToString([]byte(value))
+		}
+		return "/" + name + "/" + value
+	}
+
+	path := "/metrics" + segment("job", job)
+	for i := 0; i+1 < len(tags); i += 2 {
+		path += segment(tags[i], tags[i+1])
+	}
+
+	return path
+}
+
+// WritePushgatewayMetrics writes m in the Prometheus text format, with the
+// names of the metrics of httpcheck serve.
+func WritePushgatewayMetrics(w io.Writer, m PushMetrics) error {
+	mw := newMetricWriter(w)
+	mw.gauge("httpcheck_last_run_timestamp_seconds", "Time of the last run since the Unix epoch.", float64(m.Time.Unix()))
+	for _, v := range m.Values {
+		switch v.Name {
+		case "success":
+			mw.gauge("probe_success", "Whether the probe succeeded, with a status below 400.", float64(v.Value))
+		case "status":
+			mw.gauge("probe_http_status_code", "Status of the response.", float64(v.Value))
+		case "body_size":
+			mw.gauge("probe_http_content_length", "Size of the response body, in bytes.", float64(v.Value))
+		case "cert_expiry":
+			mw.gauge("probe_tls_cert_expiry_timestamp_seconds", "Expiry of the leaf certificate of the server since the Unix epoch.", float64(v.Value))
+		}
+	}
+	if len(m.Phases) > 0 {
+		phases, total := m.Phases[:len(m.Phases)-1], m.Phases[len(m.Phases)-1]
+		mw.gauge("probe_duration_seconds", "Total time of the request, as httpcheck's TOTAL.", seconds(total.Value))
+		mw.family("probe_http_phase_duration_seconds", "gauge", "Time of each phase of the request, as httpcheck's columns.")
+		for _, v := range phases {
+			mw.sample("probe_http_phase_duration_seconds", seconds(v.Value), "phase", v.Name)
+		}
+	}
+
+	return mw.err
+}
+
+// pushRequest sends req and checks that its status is a success.
+func pushRequest(req *http.Request) error {
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer close(resp.Body)
+	if resp.StatusCode >= 300 {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("%s: status %d: %s", req.URL.Redacted(), resp.StatusCode, strings.TrimSpace(string(b)))
+	}
+
+	return nil
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// ReportEntry is a single request of a timing report.
+type ReportEntry struct {
+	Name   string
+	Result *Result
+	Err    error
+}
+
+// reportColumns are the timing columns of a report, in milliseconds.
+var reportColumns = []struct {
+	name   string
+	metric func(r *Result) int64
+}{
+	{"DNS", func(r *Result) int64 { return r.MetricDNSLookup }},
+	{"CONNECT", func(r *Result) int64 { return r.MetricTCPConnection + r.MetricSocketConnect }},
+	{"TLS", func(r *Result) int64 { return r.MetricTLSHandshake }},
+	{"UPLOAD", func(r *Result) int64 { return r.MetricRequestUpload }},
+	{"SERVER", func(r *Result) int64 { return r.MetricServerProcessing }},
+	{"TRANSFER", func(r *Result) int64 { return r.MetricContentTransfer }},
+	{"TOTAL", (*Result).Total},
+}
+
+// PrintReport writes a table with the timings of each entry, followed by the
+// statistics of the ones with a result. An entry can have both a result and
+// an error, like a response failing an assertion.
+func PrintReport(entries []ReportEntry, opts ...PrintOption) error {
+	options := &printOptions{
+		out:   os.Stdout,
+		color: true,
+	}
+	for _, o := range opts {
+		o(options)
+	}
+	green, red, cyan := green, red, cyan
+	if !options.color {
+		green, red, cyan = noColor, noColor, noColor
+	}
+
+	header := []string{"NAME", "STATUS"}
+	for _, c := range reportColumns {
+		header = append(header, c.name)
+	}
+	rows := [][]string{header}
+
+	var results []*Result
+	failed := make(map[int]bool)
+	for i, e := range entries {
+		if e.Err != nil {
+			failed[i+1] = true
+		}
+		if e.Result == nil {
+			rows = append(rows, []string{e.Name, "error"})
+			continue
+		}
+		results = append(results, e.Result)
+		row := []string{e.Name, e.Result.Status}
+		for _, c := range reportColumns {
+			row = append(row, fmtms(c.metric(e.Result)))
+		}
+		rows = append(rows, row)
+	}
+	if len(results) > 1 {
+		rows = append(rows, nil)
+		for _, stat := range []string{"min", "avg", "p50", "p95", "max"} {
+			row := []string{stat, ""}
+			for _, c := range reportColumns {
+				values := make([]int64, len(results))
+				for i, r := range results {
+					values[i] = c.metric(r)
+				}
+				row = append(row, fmtms(statistic(stat, values)))
+			}
+			rows = append(rows, row)
+		}
+	}
+
+	for i, line := range formatTable(rows) {
+		switch {
+		case i == 0:
+			line = green(line)
+		case failed[i]:
+			line = red(line)
+		}
+		fmt.Fprintln(options.out, line)
+	}
+	for _, e := range entries {
+		if e.Err != nil {
+			fmt.Fprintf(options.out, "%s %s: %s\n", red("error:"), e.Name, e.Err)
+		}
+	}
+
+	summary := fmt.Sprintf("%d requests, %d failed", len(entries), len(failed))
+	if len(results) > 0 {
+		var total int64
+		for _, r := range results {
+			total += r.Total()
+		}
+		summary += ", " + fmtms(total) + " in total"
+	}
+	_, err := fmt.Fprintf(options.out, "\n%s\n", cyan(summary))
+
+	return err
+}
+
+// formatTable aligns the cells of rows in columns separated by two spaces.
+// The first column is aligned to the left, the others to the right.
+func formatTable(rows [][]string) []string {
+	var widths []int
+	for _, row := range rows {
+		for i, cell := range row {
+			if i >= len(widths) {
+				widths = append(widths, 0)
+			}
+			widths[i] = max(widths[i], utf8.RuneCountInString(cell))
+		}
+	}
+
+	lines := make([]string, len(rows))
+	for i, row := range rows {
+		var b strings.Builder
+		for j, cell := range row {
+			pad := strings.Repeat(" ", widths[j]-utf8.RuneCountInString(cell))
+			if j == 0 {
+				b.WriteString(cell + pad)
+				continue
+			}
+			b.WriteString("  " + pad + cell)
+		}
+		lines[i] = strings.TrimRight(b.String(), " ")
+	}
+
+	return lines
+}
+
+// statistic returns the stat ("min", "avg", "p50", "p95" or "max") of
+// values, with nearest-rank percentiles.
+func statistic(stat string, values []int64) int64 {
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	switch stat {
+	case "min":
+		return sorted[0]
+	case "max":
+		return sorted[len(sorted)-1]
+	case "avg":
+		var sum int64
+		for _, v := range sorted {
+			sum += v
+		}
+		return int64(math.Round(float64(sum) / float64(len(sorted))))
+	case "p50":
+		return percentile(sorted, 50)
+	case "p95":
+		return percentile(sorted, 95)
+	}
+
+	return 0
+}
+
+// percentile returns the nearest-rank percentile p of sorted.
+func percentile(sorted []int64, p float64) int64 {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+
+	return sorted[max(rank, 1)-1]
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrintReport(t *testing.T) {
+	result := func(status string, dns, connect, server, transfer int64) *Result {
+		return &Result{
+			Status:                 status,
+			MetricDNSLookup:        dns,
+			MetricTCPConnection:    connect,
+			MetricServerProcessing: server,
+			MetricContentTransfer:  transfer,
+		}
+	}
+	entries := []ReportEntry{
+		{Name: "list", Result: result("200", 12, 30, 85, 2)},
+		{Name: "admin / create", Result: result("201", 0, 0, 140, 1)},
+		{Name: "admin / update", Err: errors.New("unknown variable 'id'")},
+		{Name: "health", Result: result("404", 0, 0, 4, 0)},
+	}
+	var out bytes.Buffer
+
+	err := PrintReport(entries, WithOut(&out), WithNoColor())
+
+	require.NoError(t, err)
+	goldenAssert(t, "report.golden", out.String())
+}
+
+func TestStatistic(t *testing.T) {
+	values := []int64{5, 1, 4, 2, 3, 10}
+
+	assert.Equal(t, int64(1), statistic("min", values))
+	assert.Equal(t, int64(10), statistic("max", values))
+	assert.Equal(t, int64(4), statistic("avg", values))
+	assert.Equal(t, int64(3), statistic("p50", values))
+	assert.Equal(t, int64(10), statistic("p95", values))
+}
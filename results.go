@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// resultFormats are the formats of the results written with --results-out.
+var resultFormats = []string{"vegeta", "jtl"}
+
+// vegetaResult is a result of Vegeta, as read by "vegeta report" and
+// "vegeta plot", with the phases of httpcheck.
+type vegetaResult struct {
+	Attack    string        `json:"attack"`
+	Seq       uint64        `json:"seq"`
+	Code      int           `json:"code"`
+	Timestamp time.Time     `json:"timestamp"`
+	Latency   time.Duration `json:"latency"`
+	BytesOut  int64         `json:"bytes_out"`
+	BytesIn   int64         `json:"bytes_in"`
+	Error     string        `json:"error"`
+	Body      []byte        `json:"body"`
+	Method    string        `json:"method"`
+	URL       string        `json:"url"`
+	Headers   http.Header   `json:"headers"`
+
+	// Phases are the durations of the phases making up the latency, in
+	// nanoseconds, keyed by the names of the report columns.
+	Phases map[string]time.Duration `json:"phases,omitempty"`
+}
+
+// jtlColumns are the columns of a JMeter JTL file, in the default order.
+var jtlColumns = []string{
+	"timeStamp", "elapsed", "label", "responseCode", "responseMessage", "threadName", "dataType", "success",
+	"failureMessage", "bytes", "sentBytes", "grpThreads", "allThreads", "URL", "Latency", "IdleTime", "Connect",
+}
+
+// WriteResults writes entries to w in format, "vegeta" for JSON lines of
+// Vegeta results or "jtl" for a JMeter CSV results file. threads is the
+// number of requests sent at the same time. Failed entries are written with
+// the time they are written at.
+func WriteResults(w io.Writer, entries []ReportEntry, format string, threads int) error {
+	switch format {
+	case "vegeta":
+		return writeVegetaResults(w, entries)
+	case "jtl":
+		return writeJTLResults(w, entries, threads)
+	}
+
+	return fmt.Errorf("unknown results format '%s', must be one of: %s", format, strings.Join(resultFormats, ", "))
+}
+
+func writeVegetaResults(w io.Writer, entries []ReportEntry) error {
+	enc := json.NewEncoder(w)
+	for i, e := range entries {
+		v := vegetaResult{
+			Attack:    "httpcheck",
+			Seq:       uint64(i),
+			Timestamp: time.Now(),
+		}
+		if e.Err != nil {
+			v.Error = e.Err.Error()
+		}
+		if r := e.Result; r != nil {
+			v.Code, _ = strconv.Atoi(r.Status)
+			v.Timestamp = r.StartedAt
+			v.Latency = time.Duration(r.Total()) * time.Millisecond
+			v.BytesOut = max(r.RequestBodySize, 0)
+			v.BytesIn = r.BodySize
+			v.Method = r.Method
+			v.URL = r.RequestURL
+			v.Headers = http.Header{}
+			for _, h := range r.Headers {
+				v.Headers.Add(h.Name, h.Value)
+			}
+			v.Phases = map[string]time.Duration{}
+			for _, c := range reportColumns[:len(reportColumns)-1] {
+				v.Phases[strings.ToLower(c.name)] = time.Duration(c.metric(r)) * time.Millisecond
+			}
+		}
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writeJTLResults(w io.Writer, entries []ReportEntry, threads int) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(jtlColumns); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		row := map[string]string{
+			"timeStamp":  strconv.FormatInt(time.Now().UnixMilli(), 10),
+			"elapsed":    "0",
+			"label":      e.Name,
+			"threadName": "httpcheck",
+			"dataType":   "text",
+			"success":    "false",
+			"bytes":      "0",
+			"sentBytes":  "0",
+			"grpThreads": strconv.Itoa(threads),
+			"allThreads": strconv.Itoa(threads),
+			"Latency":    "0",
+			"IdleTime":   "0",
+			"Connect":    "0",
+		}
+		if r := e.Result; r != nil {
+			status, _ := strconv.Atoi(r.Status)
+			row["timeStamp"] = strconv.FormatInt(r.StartedAt.UnixMilli(), 10)
+			row["elapsed"] = strconv.FormatInt(r.Total(), 10)
+			row["responseCode"] = r.Status
+			row["responseMessage"] = http.StatusText(status)
+			row["success"] = strconv.FormatBool(e.Err == nil && status < 400)
+			row["bytes"] = strconv.FormatInt(r.BodySize, 10)
+			row["sentBytes"] = strconv.FormatInt(max(r.RequestBodySize, 0), 10)
+			row["URL"] = r.RequestURL
+			// JMeter's latency is the time to the first byte, and its
+			// connect time includes the DNS lookup and the TLS handshake.
+			row["Latency"] = strconv.FormatInt(r.Total()-r.MetricContentTransfer, 10)
+			row["Connect"] = strconv.FormatInt(r.MetricDNSLookup+r.MetricTCPConnection+r.MetricSocketConnect+r.MetricTLSHandshake, 10)
+		}
+		if e.Err != nil {
+			row["failureMessage"] = e.Err.Error()
+			if e.Result == nil {
+				row["responseCode"] = "Non HTTP response code"
+				row["responseMessage"] = e.Err.Error()
+			}
+		}
+
+		record := make([]string, len(jtlColumns))
+		for i, c := range jtlColumns {
+			record[i] = row[c]
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+
+	return cw.Error()
+}
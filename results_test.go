@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func resultsEntries() []ReportEntry {
+	startedAt := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	return []ReportEntry{
+		{
+			Name: "GET example.com",
+			Result: &Result{
+				Method:                 "GET",
+				RequestURL:             "http://example.com",
+				Status:                 "200",
+				Headers:                []Header{{Name: "Content-Type", Value: "text/html"}},
+				BodySize:               1256,
+				StartedAt:              startedAt,
+				MetricDNSLookup:        12,
+				MetricTCPConnection:    30,
+				MetricServerProcessing: 85,
+				MetricContentTransfer:  2,
+			},
+		},
+		{
+			Name: "POST example.com/items",
+			Result: &Result{
+				Method:                 "POST",
+				RequestURL:             "https://example.com/items",
+				Status:                 "503",
+				RequestBodySize:        8,
+				StartedAt:              startedAt.Add(time.Second),
+				MetricTLSHandshake:     40,
+				MetricRequestUpload:    1,
+				MetricServerProcessing: 20,
+			},
+		},
+	}
+}
+
+func TestWriteResults_vegeta(t *testing.T) {
+	entries := append(resultsEntries(), ReportEntry{Name: "example.org", Err: errors.New("connection refused")})
+	var out bytes.Buffer
+
+	err := WriteResults(&out, entries, "vegeta", 4)
+
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 3)
+	var r vegetaResult
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &r))
+	assert.Equal(t, "httpcheck", r.Attack)
+	assert.Equal(t, 200, r.Code)
+	assert.Equal(t, 129*time.Millisecond, r.Latency)
+	assert.Equal(t, int64(1256), r.BytesIn)
+	assert.Equal(t, "text/html", r.Headers.Get("Content-Type"))
+	assert.Equal(t, 85*time.Millisecond, r.Phases["server"])
+	assert.NotContains(t, r.Phases, "total")
+	require.NoError(t, json.Unmarshal([]byte(lines[2]), &r))
+	assert.Equal(t, uint64(2), r.Seq)
+	assert.Equal(t, 0, r.Code)
+	assert.Equal(t, "connection refused", r.Error)
+}
+
+func TestWriteResults_jtl(t *testing.T) {
+	var out bytes.Buffer
+
+	err := WriteResults(&out, resultsEntries(), "jtl", 4)
+
+	require.NoError(t, err)
+	goldenAssert(t, "results.jtl.golden", out.String())
+
+	out.Reset()
+	err = WriteResults(&out, []ReportEntry{{Name: "example.org", Err: errors.New("connection refused")}}, "jtl", 1)
+	require.NoError(t, err)
+	records, err := csv.NewReader(&out).ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"example.org", "Non HTTP response code", "connection refused", "false", "connection refused"},
+		[]string{records[1][2], records[1][3], records[1][4], records[1][7], records[1][8]})
+}
+
+func TestWriteResults_unknownFormat(t *testing.T) {
+	err := WriteResults(&bytes.Buffer{}, nil, "csv", 1)
+	assert.EqualError(t, err, "unknown results format 'csv', must be one of: vegeta, jtl")
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+func newRunCommand(opts *Options, setup func(*cobra.Command) (ConfigValues, error)) *cobra.Command {
+	var variables []string
+
+	cmd := &cobra.Command{
+		Use:   "run FILE [REQUEST...]",
+		Short: "Trace the requests of a .http or .rest file",
+		Long: `Trace the requests of a .http or .rest file, in the format of the VS Code
+REST Client and the JetBrains HTTP Client. Requests are selected by their
+name (### name, or # @name name) or their position, starting at 1.`,
+		Example: `httpcheck run api.http
+httpcheck run api.http login 3
+httpcheck run api.http --var host=localhost:8080`,
+		SilenceUsage: true,
+		Args:         cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			config, err := setup(cmd)
+			if err != nil {
+				return err
+			}
+			if err := config.ApplyHeaders(opts.Header); err != nil {
+				return err
+			}
+
+			vars, err := parseVariables(variables)
+			if err != nil {
+				return err
+			}
+
+			file, err := LoadHTTPFile(args[0])
+			if err != nil {
+				return err
+			}
+			requests, err := file.Select(args[1:])
+			if err != nil {
+				return err
+			}
+
+			return file.Run(cmd.Context(), requests, opts, vars, cmd.OutOrStdout(), WithShowBody(opts.ShowBody), WithMaxBodySize(opts.maxBodySize))
+		},
+	}
+
+	cmd.Flags().StringArrayVar(&variables, "var", nil, "set the variable `name=value`, overriding the file variables")
+
+	return cmd
+}
+
+func newPostmanCommand(opts *Options, setup func(*cobra.Command) (ConfigValues, error)) *cobra.Command {
+	var (
+		environment string
+		variables   []string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "postman COLLECTION [REQUEST...]",
+		Short: "Trace the requests of a Postman collection",
+		Long: `Trace the requests of a Postman v2.1 collection and print a timing report.
+Requests are selected by their name, their folder or their position, starting
+at 1. Nested requests are named after their folders, like "users / create".
+Pre-request and test scripts are not run.`,
+		Example: `httpcheck postman api.postman_collection.json
+httpcheck postman api.postman_collection.json -e staging.postman_environment.json
+httpcheck postman api.postman_collection.json users "orders / list"`,
+		SilenceUsage: true,
+		Args:         cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			config, err := setup(cmd)
+			if err != nil {
+				return err
+			}
+			if err := config.ApplyHeaders(opts.Header); err != nil {
+				return err
+			}
+
+			collection, err := LoadPostmanCollection(args[0])
+			if err != nil {
+				return err
+			}
+			var env *PostmanEnvironment
+			if environment != "" {
+				if env, err = LoadPostmanEnvironment(environment); err != nil {
+					return err
+				}
+			}
+			overrides, err := parseVariables(variables)
+			if err != nil {
+				return err
+			}
+			vars := collection.Variables(env)
+			for k, v := range overrides {
+				vars[k] = v
+			}
+
+			requests, err := collection.Select(args[1:])
+			if err != nil {
+				return err
+			}
+			entries, err := collection.Run(cmd.Context(), requests, opts, vars)
+			if err != nil {
+				return err
+			}
+			if err := PrintReport(entries, terminalOptions(cmd.OutOrStdout())...); err != nil {
+				return err
+			}
+			failed := 0
+			for _, e := range entries {
+				if e.Err != nil {
+					failed++
+				}
+			}
+			if failed > 0 {
+				return fmt.Errorf("%d of %d requests failed", failed, len(entries))
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&environment, "environment", "e", "", "read the variables of the Postman environment `file`")
+	cmd.Flags().StringArrayVar(&variables, "var", nil, "set the variable `name=value`, overriding the collection and environment variables")
+
+	return cmd
+}
+
+func newScenarioCommand(opts *Options, setup func(*cobra.Command) (ConfigValues, error)) *cobra.Command {
+	var variables []string
+
+	cmd := &cobra.Command{
+		Use:   "scenario FILE",
+		Short: "Trace the steps of a scenario, like a user journey",
+		Long: `Trace the steps of a scenario file, one after the other, and print the
+timings of each step. Values extracted from a response (json, header, regex or
+cookie) are variables of the next steps, and each step can assert its status,
+headers, body, JSON values and total time. The scenario stops at the first
+failed step.`,
+		Example: `httpcheck scenario checkout.yaml
+httpcheck scenario checkout.yaml --var host=localhost:8080`,
+		SilenceUsage: true,
+		Args:         cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			config, err := setup(cmd)
+			if err != nil {
+				return err
+			}
+			if err := config.ApplyHeaders(opts.Header); err != nil {
+				return err
+			}
+
+			vars, err := parseVariables(variables)
+			if err != nil {
+				return err
+			}
+			scenario, err := LoadScenario(args[0])
+			if err != nil {
+				return err
+			}
+			entries, err := scenario.Run(cmd.Context(), opts, vars)
+			if err != nil {
+				return err
+			}
+			if err := PrintReport(entries, terminalOptions(cmd.OutOrStdout())...); err != nil {
+				return err
+			}
+			if last := entries[len(entries)-1]; last.Err != nil {
+				return fmt.Errorf("step '%s' failed", last.Name)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringArrayVar(&variables, "var", nil, "set the variable `name=value`, overriding the scenario variables")
+
+	return cmd
+}
+
+func newServeCommand(opts *Options, setup func(*cobra.Command) (ConfigValues, error)) *cobra.Command {
+	var listen string
+
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve a Prometheus exporter probing targets",
+		Long: `Serve a Prometheus exporter probing targets, like the Blackbox exporter,
+with the phases of httpcheck. /probe?target=URL traces the target and returns
+the duration of each phase, the status, the size of the body and the expiry
+of the TLS certificate as metrics. The module parameter selects a profile of
+the config files, whose flags apply to the probe. /metrics returns the
+metrics of the exporter itself.`,
+		Example: `httpcheck serve
+httpcheck serve --listen :9615 --timeout 5s
+curl 'localhost:9615/probe?target=example.com&module=staging'`,
+		SilenceUsage: true,
+		Args:         cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			config, err := setup(cmd)
+			if err != nil {
+				return err
+			}
+			if err := config.ApplyHeaders(opts.Header); err != nil {
+				return err
+			}
+
+			wd, err := os.Getwd()
+			if err != nil {
+				return err
+			}
+			configs, err := LoadConfigs(wd)
+			if err != nil {
+				return err
+			}
+			all := pflag.NewFlagSet("all", pflag.ContinueOnError)
+			all.AddFlagSet(cmd.Root().Flags())
+			all.AddFlagSet(cmd.Root().PersistentFlags())
+			exporter := NewExporter(ConfigModules(opts, configs, all, cmd.Flags().Changed))
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+			defer stop()
+			fmt.Fprintf(cmd.ErrOrStderr(), "listening on %s\n", listen)
+
+			return exporter.Serve(ctx, listen)
+		},
+	}
+
+	cmd.Flags().StringVar(&listen, "listen", ":9615", "listen on `address`")
+
+	return cmd
+}
+
+func newReplayCommand(opts *Options, setup func(*cobra.Command) (ConfigValues, error)) *cobra.Command {
+	var match string
+
+	cmd := &cobra.Command{
+		Use:   "replay FILE [ENTRY...]",
+		Short: "Replay the requests of a HAR file and compare their timings",
+		Long: `Replay the requests of a HAR file, like the ones exported by the developer
+tools of browsers, and compare their timings with the recorded ones. Entries
+are selected by their position, starting at 1, and by their URL with --match.
+The requests are sent with the recorded headers, including their cookies.`,
+		Example: `httpcheck replay www.example.com.har
+httpcheck replay www.example.com.har 1 4
+httpcheck replay www.example.com.har --match '/api/'`,
+		SilenceUsage: true,
+		Args:         cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if _, err := setup(cmd); err != nil {
+				return err
+			}
+
+			har, err := LoadHAR(args[0])
+			if err != nil {
+				return err
+			}
+			var filter func(string) bool
+			if match != "" {
+				re, err := regexp.Compile(match)
+				if err != nil {
+					return fmt.Errorf("invalid --match: %w", err)
+				}
+				filter = re.MatchString
+			}
+			entries, err := har.Select(args[1:], filter)
+			if err != nil {
+				return err
+			}
+			if len(entries) == 0 {
+				return fmt.Errorf("no entry to replay")
+			}
+
+			replayed := Replay(cmd.Context(), entries, opts)
+			if err := PrintReplay(replayed, terminalOptions(cmd.OutOrStdout())...); err != nil {
+				return err
+			}
+			failed := 0
+			for _, e := range replayed {
+				if e.Err != nil {
+					failed++
+				}
+			}
+			if failed > 0 {
+				return fmt.Errorf("%d of %d requests failed", failed, len(replayed))
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&match, "match", "", "only replay the entries whose URL matches `regexp`")
+
+	return cmd
+}
+
+// parseVariables parses name=value variables.
+func parseVariables(variables []string) (map[string]string, error) {
+	vars := make(map[string]string, len(variables))
+	for _, v := range variables {
+		name, value, ok := strings.Cut(v, "=")
+		if !ok {
+			return nil, fmt.Errorf("'%s' is not a valid variable, use name=value", v)
+		}
+		vars[name] = value
+	}
+
+	return vars, nil
+}
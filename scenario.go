@@ -0,0 +1,412 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Scenario is an ordered list of requests, like a user journey. Values
+// extracted from a response are variables of the next steps.
+type Scenario struct {
+	Name      string            `yaml:"name"`
+	Variables map[string]string `yaml:"variables"`
+	Steps     []ScenarioStep    `yaml:"steps"`
+}
+
+// ScenarioStep is a request of a scenario. The URL, items and body can
+// reference variables with "{{name}}".
+type ScenarioStep struct {
+	Name   string   `yaml:"name"`
+	Method string   `yaml:"method"`
+	URL    string   `yaml:"url"`
+	Items  []string `yaml:"items"`
+	Body   string   `yaml:"body"`
+	// Extract maps variable names to the part of the response they are
+	// read from.
+	Extract map[string]ScenarioExtract `yaml:"extract"`
+	Assert  ScenarioAssert             `yaml:"assert"`
+}
+
+// ScenarioExtract reads a value from a response: a JSONPath in the body, like
+// "$.items[0].id", a header, the first group (or the match) of a regular
+// expression on the body, or a cookie.
+type ScenarioExtract struct {
+	JSON   string `yaml:"json"`
+	Header string `yaml:"header"`
+	Regex  string `yaml:"regex"`
+	Cookie string `yaml:"cookie"`
+}
+
+// ScenarioAssert checks a response. Headers and Body must be contained in
+// the response, JSON maps JSONPaths to their expected value, and MaxTime is
+// the maximum total time.
+type ScenarioAssert struct {
+	Status  statusList        `yaml:"status"`
+	Headers map[string]string `yaml:"headers"`
+	Body    string            `yaml:"body"`
+	JSON    map[string]string `yaml:"json"`
+	MaxTime time.Duration     `yaml:"max-time"`
+}
+
+// statusList is a status code, or a list of them.
+type statusList []int
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (s *statusList) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		var status int
+		if err := value.Decode(&status); err != nil {
+			return err
+		}
+		*s = statusList{status}
+		return nil
+	}
+
+	return value.Decode((*[]int)(s))
+}
+
+// LoadScenario reads the scenario file at path.
+func LoadScenario(path string) (*Scenario, error) {
+	b, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	s := &Scenario{}
+	d := yaml.NewDecoder(bytes.NewReader(b))
+	d.KnownFields(true)
+	if err := d.Decode(s); err != nil {
+		return nil, fmt.Errorf("invalid scenario '%s': %w", path, err)
+	}
+	if len(s.Steps) == 0 {
+		return nil, fmt.Errorf("invalid scenario '%s': no steps", path)
+	}
+	for i, step := range s.Steps {
+		if step.URL == "" {
+			return nil, fmt.Errorf("invalid scenario '%s': step %d has no url", path, i+1)
+		}
+		for name, e := range step.Extract {
+			n := 0
+			for _, v := range []string{e.JSON, e.Header, e.Regex, e.Cookie} {
+				if v != "" {
+					n++
+				}
+			}
+			if n != 1 {
+				return nil, fmt.Errorf("invalid scenario '%s': extract '%s' must have one of json, header, regex or cookie", path, name)
+			}
+		}
+	}
+
+	return s, nil
+}
+
+// stepName returns the name of the step i, starting at 0.
+func (s *Scenario) stepName(i int) string {
+	if name := s.Steps[i].Name; name != "" {
+		return name
+	}
+
+	return fmt.Sprintf("step %d", i+1)
+}
+
+// Run traces the steps one after the other, sharing their cookies, and
+// returns a report entry for each. It stops at the first step that fails,
+// since the next ones usually depend on it.
+func (s *Scenario) Run(ctx context.Context, base *Options, variables map[string]string) ([]ReportEntry, error) {
+	base = base.clone()
+	if base.Jar == nil {
+		jar, err := newSessionJar()
+		if err != nil {
+			return nil, err
+		}
+		base.Jar = jar
+	}
+	r := &httpFileResolver{variables: map[string]string{}, values: map[string]string{}}
+	for k, v := range s.Variables {
+		r.variables[k] = v
+	}
+	for k, v := range variables {
+		r.variables[k] = v
+	}
+
+	entries := make([]ReportEntry, 0, len(s.Steps))
+	for i, step := range s.Steps {
+		e := ReportEntry{Name: s.stepName(i)}
+		opts, err := step.options(base, r)
+		if err == nil {
+			if e.Result, err = Trace(ctx, opts); err == nil {
+				err = step.check(e.Result, opts, r)
+			}
+		}
+		e.Err = err
+		entries = append(entries, e)
+		if err != nil {
+			break
+		}
+	}
+
+	return entries, nil
+}
+
+// options returns the options of the step, based on base. The variables
+// are resolved once: their values, which can come from a response, are
+// sent literally.
+func (step ScenarioStep) options(base *Options, r *httpFileResolver) (*Options, error) {
+	o := base.clone()
+	u, err := r.resolveEscaped(step.URL, escapeURL)
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, 0, len(step.Items)+1)
+	args = append(args, u)
+	for _, item := range step.Items {
+		v, err := r.resolveEscaped(item, escapeItem)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, v)
+	}
+	if step.Method != "" {
+		o.Method = strings.ToUpper(step.Method)
+		o.explicitMethod = true
+	}
+	if step.Body != "" {
+		body, err := r.resolve(step.Body)
+		if err != nil {
+			return nil, err
+		}
+		o.RawBody = []byte(body)
+	}
+	if err := ParseLiteralArgs(args, o); err != nil {
+		return nil, err
+	}
+
+	return o, nil
+}
+
+// check runs the assertions of the step on result, then adds the extracted
+// values to the variables of r.
+func (step ScenarioStep) check(result *Result, opts *Options, r *httpFileResolver) error {
+	resp, err := newScenarioResponse(result, opts)
+	if err != nil {
+		return err
+	}
+
+	a := step.Assert
+	if len(a.Status) > 0 && !slices.Contains(a.Status, resp.status) {
+		return fmt.Errorf("status is %d, want %s", resp.status, strings.Trim(fmt.Sprint([]int(a.Status)), "[]"))
+	}
+	for _, name := range sortedKeys(a.Headers) {
+		want := a.Headers[name]
+		if got := result.Header(name); !strings.Contains(got, want) {
+			return fmt.Errorf("header %s is '%s', want '%s'", http.CanonicalHeaderKey(name), got, want)
+		}
+	}
+	if a.Body != "" && !strings.Contains(string(resp.body), a.Body) {
+		return fmt.Errorf("body does not contain '%s'", a.Body)
+	}
+	for _, path := range sortedKeys(a.JSON) {
+		got, err := resp.jsonPath(path)
+		if err != nil {
+			return err
+		}
+		if want := a.JSON[path]; got != want {
+			return fmt.Errorf("%s is '%s', want '%s'", path, got, want)
+		}
+	}
+	if total := time.Duration(result.Total()) * time.Millisecond; a.MaxTime > 0 && total > a.MaxTime {
+		return fmt.Errorf("total time is %s, want at most %s", total, a.MaxTime)
+	}
+
+	for _, name := range sortedKeys(step.Extract) {
+		v, err := resp.extract(step.Extract[name])
+		if err != nil {
+			return fmt.Errorf("cannot extract '%s': %w", name, err)
+		}
+		r.values[name] = v
+	}
+
+	return nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	return keys
+}
+
+// scenarioResponse is a response checked by a step.
+type scenarioResponse struct {
+	result *Result
+	status int
+	body   []byte
+	jar    http.CookieJar
+	url    *url.URL
+
+	// data is the decoded JSON body, once needed.
+	data    any
+	decoded bool
+}
+
+func newScenarioResponse(result *Result, opts *Options) (*scenarioResponse, error) {
+	status, err := strconv.Atoi(result.Status)
+	if err != nil {
+		return nil, fmt.Errorf("invalid status '%s'", result.Status)
+	}
+	body, err := os.ReadFile(result.Output)
+	if err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(result.RequestURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &scenarioResponse{result: result, status: status, body: body, jar: opts.Jar, url: u}, nil
+}
+
+func (resp *scenarioResponse) extract(e ScenarioExtract) (string, error) {
+	switch {
+	case e.JSON != "":
+		return resp.jsonPath(e.JSON)
+	case e.Header != "":
+		v := resp.result.Header(e.Header)
+		if v == "" {
+			return "", fmt.Errorf("no header %s", http.CanonicalHeaderKey(e.Header))
+		}
+		return v, nil
+	case e.Regex != "":
+		re, err := regexp.Compile(e.Regex)
+		if err != nil {
+			return "", err
+		}
+		m := re.FindSubmatch(resp.body)
+		if m == nil {
+			return "", fmt.Errorf("no match for '%s'", e.Regex)
+		}
+		if len(m) > 1 {
+			return string(m[1]), nil
+		}
+		return string(m[0]), nil
+	case e.Cookie != "":
+		for _, c := range resp.jar.Cookies(resp.url) {
+			if c.Name == e.Cookie {
+				return c.Value, nil
+			}
+		}
+		return "", fmt.Errorf("no cookie %s", e.Cookie)
+	}
+
+	return "", errors.New("nothing to extract")
+}
+
+// jsonPath returns the value at path in the JSON body, as a string.
+func (resp *scenarioResponse) jsonPath(path string) (string, error) {
+	if !resp.decoded {
+		if err := json.Unmarshal(resp.body, &resp.data); err != nil {
+			return "", fmt.Errorf("body is not JSON: %w", err)
+		}
+		resp.decoded = true
+	}
+	v, err := jsonPath(resp.data, path)
+	if err != nil {
+		return "", err
+	}
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
+
+// jsonPath returns the value at path in data. Only a subset of JSONPath is
+// supported: the root "$", children like ".name" or "['name']", and array
+// indexes like "[0]", negative ones counting from the end.
+func jsonPath(data any, path string) (any, error) {
+	rest, ok := strings.CutPrefix(path, "$")
+	if !ok {
+		return nil, fmt.Errorf("invalid JSONPath '%s', it must start with $", path)
+	}
+
+	v := data
+	for rest != "" {
+		var key string
+		index, isIndex := 0, false
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			key, rest = rest[:end], rest[end:]
+			if key == "" {
+				return nil, fmt.Errorf("invalid JSONPath '%s'", path)
+			}
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid JSONPath '%s'", path)
+			}
+			selector := rest[1:end]
+			rest = rest[end+1:]
+			if len(selector) >= 2 && (selector[0] == '\'' || selector[0] == '"') && selector[len(selector)-1] == selector[0] {
+				key = selector[1 : len(selector)-1]
+				break
+			}
+			n, err := strconv.Atoi(selector)
+			if err != nil {
+				return nil, fmt.Errorf("invalid JSONPath '%s': unsupported selector '[%s]'", path, selector)
+			}
+			index, isIndex = n, true
+		default:
+			return nil, fmt.Errorf("invalid JSONPath '%s'", path)
+		}
+
+		if isIndex {
+			a, ok := v.([]any)
+			if !ok {
+				return nil, fmt.Errorf("%s: not an array", path)
+			}
+			if index < 0 {
+				index += len(a)
+			}
+			if index < 0 || index >= len(a) {
+				return nil, fmt.Errorf("%s: index out of range", path)
+			}
+			v = a[index]
+			continue
+		}
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("%s: not an object", path)
+		}
+		if v, ok = m[key]; !ok {
+			return nil, fmt.Errorf("%s: no key '%s'", path, key)
+		}
+	}
+
+	return v, nil
+}
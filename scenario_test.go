@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func scenarioServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		switch req.URL.Path {
+		case "/login":
+			var body map[string]string
+			require.NoError(t, json.NewDecoder(req.Body).Decode(&body))
+			assert.Equal(t, map[string]string{"user": "john", "password": "secret"}, body)
+			http.SetCookie(rw, &http.Cookie{Name: "sid", Value: "s1"})
+			_, _ = rw.Write([]byte(`{"token": "t0k"}`))
+		case "/me":
+			assert.Equal(t, "Bearer t0k", req.Header.Get("Authorization"))
+			rw.Header().Set("X-Request-Id", "r1")
+			_, _ = rw.Write([]byte(`{"name": "john", "orders": ["order-3", "order-7"]}`))
+		case "/orders/3":
+			assert.Equal(t, "s1", req.Header.Get("X-Session"))
+			assert.Equal(t, "r1", req.Header.Get("X-Request-Id"))
+			_, err := req.Cookie("sid")
+			assert.NoError(t, err)
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestScenario_Run(t *testing.T) {
+	svr := scenarioServer(t)
+	defer svr.Close()
+	s, err := LoadScenario("testdata/scenario.yaml")
+	require.NoError(t, err)
+
+	entries, err := s.Run(context.Background(), NewDefaultOptions(), map[string]string{"host": svr.URL})
+
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	for _, e := range entries {
+		assert.NoError(t, e.Err, e.Name)
+	}
+	assert.Equal(t, []string{"login", "profile", "order"}, []string{entries[0].Name, entries[1].Name, entries[2].Name})
+	assert.Equal(t, "200", entries[2].Result.Status)
+}
+
+func TestScenario_Run_assertion(t *testing.T) {
+	svr := scenarioServer(t)
+	defer svr.Close()
+	s := &Scenario{Steps: []ScenarioStep{
+		{URL: svr.URL + "/missing", Assert: ScenarioAssert{Status: statusList{200, 201}}},
+		{URL: svr.URL + "/me"},
+	}}
+
+	entries, err := s.Run(context.Background(), NewDefaultOptions(), nil)
+
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "step 1", entries[0].Name)
+	assert.NotNil(t, entries[0].Result)
+	assert.EqualError(t, entries[0].Err, "status is 404, want 200 201")
+}
+
+func TestScenario_Run_literalValues(t *testing.T) {
+	t.Setenv("SCENARIO_SECRET", "hunter2")
+	var got http.Header
+	var query string
+	svr := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/login" {
+			_, _ = rw.Write([]byte(`{"id": "${SCENARIO_SECRET}", "next": "{{$processEnv SCENARIO_SECRET}}", "q": "a&b=c"}`))
+			return
+		}
+		got, query = req.Header, req.URL.RawQuery
+	}))
+	defer svr.Close()
+	s := &Scenario{Steps: []ScenarioStep{
+		{URL: svr.URL + "/login", Extract: map[string]ScenarioExtract{"id": {JSON: "$.id"}, "next": {JSON: "$.next"}, "q": {JSON: "$.q"}}},
+		{URL: svr.URL + "/items?q={{q}}", Items: []string{"X-Id:{{id}}", "X-Next:{{next}}", `X-Env:{{ env "SCENARIO_SECRET" }}`, "X-Request-Id:{{ uuid }}"}},
+	}}
+
+	entries, err := s.Run(context.Background(), NewDefaultOptions(), nil)
+
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.NoError(t, entries[1].Err)
+	// the values of a response are never interpolated.
+	assert.Equal(t, "${SCENARIO_SECRET}", got.Get("X-Id"))
+	assert.Equal(t, "{{$processEnv SCENARIO_SECRET}}", got.Get("X-Next"))
+	assert.Equal(t, "q=a%26b%3Dc", query)
+	assert.Equal(t, "hunter2", got.Get("X-Env"))
+	assert.Len(t, got.Get("X-Request-Id"), 36)
+}
+
+func TestLoadScenario_errors(t *testing.T) {
+	cases := []struct {
+		content string
+		err     string
+	}{
+		{"steps: []", "no steps"},
+		{"steps:\n  - name: a", "step 1 has no url"},
+		{"steps:\n  - url: a\n    extract:\n      id: {}", "extract 'id' must have one of json, header, regex or cookie"},
+		{"steps:\n  - url: a\n    retries: 3", "field retries not found"},
+	}
+	for _, c := range cases {
+		path := filepath.Join(t.TempDir(), "scenario.yaml")
+		require.NoError(t, os.WriteFile(path, []byte(c.content), 0o600))
+
+		_, err := LoadScenario(path)
+
+		assert.ErrorContains(t, err, c.err)
+	}
+}
+
+func TestJSONPath(t *testing.T) {
+	var data any
+	require.NoError(t, json.Unmarshal([]byte(`{"a": {"b c": [1, {"d": true}]}, "e": null}`), &data))
+
+	cases := []struct {
+		path string
+		want any
+		err  string
+	}{
+		{path: "$", want: data},
+		{path: "$.a['b c'][0]", want: float64(1)},
+		{path: `$.a["b c"][-1].d`, want: true},
+		{path: "$.e", want: nil},
+		{path: "$.x", err: "$.x: no key 'x'"},
+		{path: "$.a['b c'][2]", err: "$.a['b c'][2]: index out of range"},
+		{path: "$.a[0]", err: "$.a[0]: not an array"},
+		{path: "$.a['b c'][*]", err: "invalid JSONPath '$.a['b c'][*]': unsupported selector '[*]'"},
+		{path: "a.b", err: "invalid JSONPath 'a.b', it must start with $"},
+	}
+	for _, c := range cases {
+		got, err := jsonPath(data, c.path)
+		if c.err != "" {
+			assert.EqualError(t, err, c.err, c.path)
+			continue
+		}
+		require.NoError(t, err, c.path)
+		assert.Equal(t, c.want, got, c.path)
+	}
+}
@@ -0,0 +1,380 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// probeSuccessStatus is the first status of a failed probe.
+const probeSuccessStatus = 400
+
+// ProbeModules returns the options of a module of the exporter, a config
+// profile. The empty module is the options of the serve command.
+type ProbeModules func(module string) (*Options, error)
+
+// errUnknownModule is returned by ProbeModules for a module that does not
+// exist.
+type errUnknownModule string
+
+func (e errUnknownModule) Error() string {
+	return fmt.Sprintf("unknown module '%s'", string(e))
+}
+
+// ConfigModules returns the modules of configs: each profile is a module,
+// the options of base updated by the flags of the profile. The flags of
+// all are the ones a profile can have, and changed reports the flags given
+// on the command line, which the profiles do not override.
+func ConfigModules(base *Options, configs []*Config, all *pflag.FlagSet, changed func(name string) bool) ProbeModules {
+	return func(module string) (*Options, error) {
+		o := base.clone()
+		if module == "" {
+			return o, nil
+		}
+		if !slices.ContainsFunc(configs, func(c *Config) bool {
+			_, ok := c.Profiles[module]
+			return ok
+		}) {
+			return nil, errUnknownModule(module)
+		}
+		values, err := resolveConfig(configs, module)
+		if err != nil {
+			return nil, err
+		}
+		for name := range values {
+			if changed(name) {
+				delete(values, name)
+			}
+		}
+
+		var proxy string
+		flags := moduleFlags(o, &proxy)
+		if err := values.ApplyFlags(flags, all); err != nil {
+			return nil, err
+		}
+		headers, _ := configMap(values["headers"])
+		for name := range headers {
+			// the headers of the profile override the default ones.
+			o.Header.Del(name)
+		}
+		if err := values.ApplyHeaders(o.Header); err != nil {
+			return nil, err
+		}
+		if proxy != "" {
+			if o.Proxy, err = url.Parse(proxy); err != nil {
+				return nil, fmt.Errorf("invalid proxy '%s': %w", proxy, err)
+			}
+		}
+
+		return o, validateAuth(o)
+	}
+}
+
+// moduleFlags returns the flags of o that a module can set.
+func moduleFlags(o *Options, proxy *string) *pflag.FlagSet {
+	flags := pflag.NewFlagSet("module", pflag.ContinueOnError)
+	flags.StringVarP(&o.Method, "method", "m", o.Method, "")
+	flags.DurationVar(&o.timeout, "timeout", o.timeout, "")
+	flags.BoolVarP(&o.Insecure, "insecure", "k", o.Insecure, "")
+	flags.BoolVarP(&o.FollowRedirect, "follow", "F", o.FollowRedirect, "")
+	flags.StringVar(proxy, "proxy", "", "")
+	flags.StringVar(&o.UnixSocket, "unix-socket", o.UnixSocket, "")
+	flags.StringVarP(&o.Auth, "auth", "a", o.Auth, "")
+	flags.StringVarP(&o.AuthType, "auth-type", "A", o.AuthType, "")
+	flags.StringVar(&o.ContentType, "content-type", o.ContentType, "")
+
+	return flags
+}
+
+// Exporter is a Prometheus exporter probing targets, like the Blackbox
+// exporter, with the phases of httpcheck.
+type Exporter struct {
+	modules ProbeModules
+	start   time.Time
+
+	mu       sync.Mutex
+	inFlight int
+	probes   map[probeKey]*probeStats
+}
+
+type probeKey struct {
+	module string
+	result string
+}
+
+type probeStats struct {
+	count    int
+	duration time.Duration
+}
+
+// NewExporter returns an exporter probing targets with the options of
+// modules.
+func NewExporter(modules ProbeModules) *Exporter {
+	return &Exporter{
+		modules: modules,
+		start:   time.Now(),
+		probes:  map[probeKey]*probeStats{},
+	}
+}
+
+// Handler returns the handler of the exporter: /probe probes a target and
+// /metrics has the metrics of the exporter itself.
+func (e *Exporter) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/probe", e.serveProbe)
+	mux.HandleFunc("/metrics", e.serveMetrics)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintln(w, "httpcheck exporter: /probe?target=example.com&module=name, /metrics")
+	})
+
+	return mux
+}
+
+// serveProbe traces the target parameter with the options of the module
+// parameter, and writes the result as metrics. A failed probe is not an
+// HTTP error, its probe_success metric is 0.
+func (e *Exporter) serveProbe(w http.ResponseWriter, r *http.Request) {
+	target := r.URL.Query().Get("target")
+	if target == "" {
+		http.Error(w, "target parameter is missing", http.StatusBadRequest)
+		return
+	}
+	module := r.URL.Query().Get("module")
+	opts, err := e.modules(module)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if _, ok := err.(errUnknownModule); ok {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	if !isToken(opts.Method) {
+		http.Error(w, fmt.Sprintf("'%s' is not a valid HTTP method", opts.Method), http.StatusBadRequest)
+		return
+	}
+	// the target comes from the network, so it must not read the
+	// environment of the exporter.
+	ParseTarget(target, opts)
+	// the probe must end before Prometheus gives up on the scrape.
+	if s := r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds"); s != "" {
+		if seconds, err := strconv.ParseFloat(s, 64); err == nil && seconds > 0 {
+			opts.timeout = min(opts.timeout, time.Duration(seconds*float64(time.Second)))
+		}
+	}
+
+	e.mu.Lock()
+	e.inFlight++
+	e.mu.Unlock()
+	start := time.Now()
+	result, err := Trace(r.Context(), opts)
+	if result != nil {
+		_ = os.Remove(result.Output)
+	}
+	e.record(module, probeSucceeded(result, err), time.Since(start))
+
+	w.Header().Set(contentTypeHeader, "text/plain; version=0.0.4; charset=utf-8")
+	_ = WriteProbeMetrics(w, result, err)
+}
+
+func probeSucceeded(r *Result, err error) bool {
+	if err != nil {
+		return false
+	}
+	status, _ := strconv.Atoi(r.Status)
+
+	return status < probeSuccessStatus
+}
+
+// record counts a probe of module.
+func (e *Exporter) record(module string, success bool, d time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.inFlight--
+	key := probeKey{module: module, result: "success"}
+	if !success {
+		key.result = "failure"
+	}
+	s, ok := e.probes[key]
+	if !ok {
+		s = &probeStats{}
+		e.probes[key] = s
+	}
+	s.count++
+	s.duration += d
+}
+
+// serveMetrics writes the metrics of the exporter: the probes by module and
+// result, and the state of the process.
+func (e *Exporter) serveMetrics(w http.ResponseWriter, _ *http.Request) {
+	e.mu.Lock()
+	keys := make([]probeKey, 0, len(e.probes))
+	for k := range e.probes {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, func(a, b probeKey) int {
+		return strings.Compare(a.module+"\x00"+a.result, b.module+"\x00"+b.result)
+	})
+	probes := make([]probeStats, len(keys))
+	for i, k := range keys {
+		probes[i] = *e.probes[k]
+	}
+	inFlight := e.inFlight
+	e.mu.Unlock()
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	w.Header().Set(contentTypeHeader, "text/plain; version=0.0.4; charset=utf-8")
+	m := newMetricWriter(w)
+	m.family("httpcheck_exporter_probes_total", "counter", "Probes by module and result.")
+	for i, k := range keys {
+		m.sample("httpcheck_exporter_probes_total", float64(probes[i].count), "module", k.module, "result", k.result)
+	}
+	m.family("httpcheck_exporter_probe_duration_seconds_total", "counter", "Time spent probing, by module and result.")
+	for i, k := range keys {
+		m.sample("httpcheck_exporter_probe_duration_seconds_total", probes[i].duration.Seconds(), "module", k.module, "result", k.result)
+	}
+	m.gauge("httpcheck_exporter_probes_in_flight", "Probes being run.", float64(inFlight))
+	m.gauge("httpcheck_exporter_start_time_seconds", "Start time of the exporter since the Unix epoch.", float64(e.start.UnixNano())/1e9)
+	m.gauge("go_goroutines", "Number of goroutines that currently exist.", float64(runtime.NumGoroutine()))
+	m.gauge("go_memstats_alloc_bytes", "Number of bytes allocated and still in use.", float64(mem.Alloc))
+	m.gauge("go_memstats_sys_bytes", "Number of bytes obtained from system.", float64(mem.Sys))
+}
+
+// WriteProbeMetrics writes the result of a probe, r or err, as Prometheus
+// metrics: the duration of each phase, the status, the size of the body and
+// the expiry of the TLS certificate.
+func WriteProbeMetrics(w io.Writer, r *Result, err error) error {
+	m := newMetricWriter(w)
+	if err != nil {
+		m.comment("error: " + err.Error())
+	}
+	success := 0.0
+	if probeSucceeded(r, err) {
+		success = 1
+	}
+	m.gauge("probe_success", "Whether the probe succeeded, with a status below 400.", success)
+	if r == nil {
+		return m.err
+	}
+
+	m.gauge("probe_duration_seconds", "Total time of the request, as httpcheck's TOTAL.", seconds(r.Total()))
+	m.family("probe_http_phase_duration_seconds", "gauge", "Time of each phase of the request, as httpcheck's columns.")
+	for _, c := range reportColumns[:len(reportColumns)-1] {
+		m.sample("probe_http_phase_duration_seconds", seconds(c.metric(r)), "phase", strings.ToLower(c.name))
+	}
+	status, _ := strconv.Atoi(r.Status)
+	m.gauge("probe_http_status_code", "Status of the response.", float64(status))
+	m.gauge("probe_http_content_length", "Size of the response body, in bytes.", float64(r.BodySize))
+	m.gauge("probe_http_ssl", "Whether the response came over TLS.", boolMetric(!r.CertExpiry.IsZero()))
+	if !r.CertExpiry.IsZero() {
+		m.gauge("probe_tls_cert_expiry_timestamp_seconds", "Expiry of the leaf certificate of the server since the Unix epoch.", float64(r.CertExpiry.Unix()))
+	}
+
+	return m.err
+}
+
+func seconds(ms int64) float64 {
+	return float64(ms) / 1000
+}
+
+func boolMetric(b bool) float64 {
+	if b {
+		return 1
+	}
+
+	return 0
+}
+
+// labelEscaper escapes a label value of the Prometheus text format.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// metricWriter writes metrics in the Prometheus text format, keeping the
+// first error.
+type metricWriter struct {
+	w   io.Writer
+	err error
+}
+
+func newMetricWriter(w io.Writer) *metricWriter {
+	return &metricWriter{w: w}
+}
+
+func (m *metricWriter) printf(format string, args ...any) {
+	if m.err == nil {
+		_, m.err = fmt.Fprintf(m.w, format, args...)
+	}
+}
+
+func (m *metricWriter) comment(text string) {
+	m.printf("# %s\n", strings.ReplaceAll(text, "\n", " "))
+}
+
+// family writes the HELP and TYPE lines of the metric name.
+func (m *metricWriter) family(name, typ, help string) {
+	m.printf("# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// gauge writes a gauge without labels.
+func (m *metricWriter) gauge(name, help string, value float64) {
+	m.family(name, "gauge", help)
+	m.sample(name, value)
+}
+
+// sample writes a sample of name with the labels, given as name and value
+// pairs.
+func (m *metricWriter) sample(name string, value float64, labels ...string) {
+	var b strings.Builder
+	b.WriteString(name)
+	if len(labels) > 0 {
+		b.WriteString("{")
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				b.WriteString(",")
+			}
+			b.WriteString(labels[i] + `="` + labelEscaper.Replace(labels[i+1]) + `"`)
+		}
+		b.WriteString("}")
+	}
+	m.printf("%s %s\n", b.String(), strconv.FormatFloat(value, 'f', -1, 64))
+}
+
+// Serve runs the exporter at addr until ctx is done.
+func (e *Exporter) Serve(ctx context.Context, addr string) error {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           e.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	errs := make(chan error, 1)
+	go func() {
+		errs <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return srv.Shutdown(shutdownCtx)
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteProbeMetrics(t *testing.T) {
+	r := &Result{
+		Status:                 "200",
+		BodySize:               1256,
+		CertExpiry:             time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC),
+		MetricDNSLookup:        12,
+		MetricTCPConnection:    25,
+		MetricTLSHandshake:     40,
+		MetricServerProcessing: 103,
+		MetricContentTransfer:  2,
+	}
+	var out bytes.Buffer
+
+	require.NoError(t, WriteProbeMetrics(&out, r, nil))
+
+	goldenAssert(t, "probe.golden", out.String())
+}
+
+func TestWriteProbeMetrics_error(t *testing.T) {
+	var out bytes.Buffer
+
+	require.NoError(t, WriteProbeMetrics(&out, nil, errors.New("dial tcp: connection refused\nretry")))
+
+	assert.Equal(t, `# error: dial tcp: connection refused retry
+# HELP probe_success Whether the probe succeeded, with a status below 400.
+# TYPE probe_success gauge
+probe_success 0
+`, out.String())
+}
+
+func TestMetricWriter_sample(t *testing.T) {
+	var out bytes.Buffer
+	m := newMetricWriter(&out)
+
+	m.sample("probes_total", 3, "module", `a "b"\c`+"\n", "result", "success")
+
+	assert.Equal(t, `probes_total{module="a \"b\"\\c\n",result="success"} 3`+"\n", out.String())
+}
+
+func TestConfigModules(t *testing.T) {
+	base := NewDefaultOptions()
+	base.Header.Set("X-Team", "core")
+	configs := []*Config{{
+		Defaults: ConfigValues{"headers": map[string]any{"X-Team": "core"}},
+		Profiles: map[string]ConfigValues{
+			"staging": {"timeout": "2s", "follow": true, "proxy": "http://proxy:3128", "headers": map[string]any{"X-Team": "qa"}},
+			"invalid": {"verbose": true},
+		},
+	}}
+	all := moduleFlags(NewDefaultOptions(), new(string))
+	modules := ConfigModules(base, configs, all, func(name string) bool { return name == "follow" })
+
+	o, err := modules("")
+	require.NoError(t, err)
+	assert.Equal(t, 10*time.Second, o.timeout)
+
+	o, err = modules("staging")
+	require.NoError(t, err)
+	assert.Equal(t, 2*time.Second, o.timeout)
+	assert.False(t, o.FollowRedirect)
+	assert.Equal(t, "http://proxy:3128", o.Proxy.String())
+	assert.Equal(t, "qa", o.Header.Get("X-Team"))
+	assert.Equal(t, "core", base.Header.Get("X-Team"))
+
+	_, err = modules("production")
+	assert.EqualError(t, err, "unknown module 'production'")
+	_, err = modules("invalid")
+	assert.EqualError(t, err, "unknown config key 'verbose'")
+}
+
+func TestExporter_Handler(t *testing.T) {
+	var rawQuery string
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rawQuery, _ = url.QueryUnescape(r.URL.RawQuery)
+		if r.Header.Get("X-Module") != "down" {
+			_, _ = w.Write([]byte("ok"))
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer target.Close()
+	exporter := NewExporter(func(module string) (*Options, error) {
+		o := NewDefaultOptions()
+		switch module {
+		case "":
+		case "down":
+			o.Header.Set("X-Module", module)
+		default:
+			return nil, errUnknownModule(module)
+		}
+		return o, nil
+	})
+	svr := httptest.NewServer(exporter.Handler())
+	defer svr.Close()
+	get := func(path string) (int, string) {
+		resp, err := http.Get(svr.URL + path)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(b)
+	}
+
+	status, body := get("/probe?target=" + url.QueryEscape(target.URL))
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, "\nprobe_success 1\n")
+	assert.Contains(t, body, "\nprobe_http_status_code 200\n")
+	assert.Contains(t, body, "\nprobe_http_content_length 2\n")
+	assert.Contains(t, body, `probe_http_phase_duration_seconds{phase="server"}`)
+
+	status, body = get("/probe?module=down&target=" + url.QueryEscape(target.URL))
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, "\nprobe_success 0\n")
+	assert.Contains(t, body, "\nprobe_http_status_code 503\n")
+
+	t.Setenv("PROBE_SECRET", "hunter2")
+	status, body = get("/probe?target=" + url.QueryEscape(target.URL+"/x?k=${PROBE_SECRET}&t={{ env \"PROBE_SECRET\" }}"))
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, "\nprobe_success 1\n")
+	assert.Equal(t, `k=${PROBE_SECRET}&t={{ env "PROBE_SECRET" }}`, rawQuery)
+
+	status, body = get("/probe?module=other&target=example.com")
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "unknown module 'other'\n", body)
+	status, _ = get("/probe")
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status, body = get("/metrics")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, `httpcheck_exporter_probes_total{module="",result="success"} 2`)
+	assert.Contains(t, body, `httpcheck_exporter_probes_total{module="down",result="failure"} 1`)
+	assert.Contains(t, body, "\nhttpcheck_exporter_probes_in_flight 0\n")
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sessionIgnoredHeaders describe a single request, so they are not
+// persisted in a session.
+var sessionIgnoredHeaders = []string{
+	"Content-Length",
+	"Content-Type",
+	"Cookie",
+	"Host",
+}
+
+// SessionHeader is a header persisted in a session.
+type SessionHeader struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// SessionAuth is the authentication persisted in a session.
+type SessionAuth struct {
+	Type       string `json:"type"`
+	Credential string `json:"credential"`
+}
+
+// SessionCookie is a cookie persisted in a session.
+type SessionCookie struct {
+	Name     string    `json:"name"`
+	Value    string    `json:"value"`
+	Domain   string    `json:"domain"`
+	HostOnly bool      `json:"host_only,omitempty"`
+	Path     string    `json:"path"`
+	Expires  time.Time `json:"expires,omitempty"`
+	Secure   bool      `json:"secure,omitempty"`
+	HTTPOnly bool      `json:"http_only,omitempty"`
+}
+
+func (c SessionCookie) expired(now time.Time) bool {
+	return !c.Expires.IsZero() && c.Expires.Before(now)
+}
+
+// Session holds the cookies, authentication and headers reused between
+// invocations, like HTTPie sessions.
+type Session struct {
+	Headers []SessionHeader `json:"headers"`
+	Cookies []SessionCookie `json:"cookies"`
+	Auth    *SessionAuth    `json:"auth,omitempty"`
+
+	path string
+	jar  *sessionJar
+}
+
+// sessionPath returns the file of the session name for the host of rawURL.
+// A name containing a path separator is used as the path itself.
+func sessionPath(name, rawURL string) (string, error) {
+	if strings.ContainsRune(name, filepath.Separator) || strings.ContainsRune(name, '/') || strings.HasSuffix(name, ".json") {
+		return filepath.Clean(name), nil
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	host := strings.ReplaceAll(u.Host, ":", "_")
+
+	return filepath.Join(dir, "httpcheck", "sessions", host, name+".json"), nil
+}
+
+// LoadSession reads the session name for rawURL. A session that does not
+// exist yet is empty.
+func LoadSession(name, rawURL string) (*Session, error) {
+	path, err := sessionPath(name, rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Session{path: path}
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, fmt.Errorf("invalid session file '%s': %w", path, err)
+	}
+
+	return s, nil
+}
+
+// Apply adds the session headers, authentication and cookies to opts. The
+// headers and authentication given on the command line win.
+func (s *Session) Apply(opts *Options) error {
+	for _, h := range s.Headers {
+		if _, ok := opts.Header[http.CanonicalHeaderKey(h.Name)]; !ok {
+			opts.Header.Add(h.Name, h.Value)
+		}
+	}
+	if opts.Auth == "" && s.Auth != nil {
+		opts.Auth = s.Auth.Credential
+		opts.AuthType = s.Auth.Type
+	}
+
+	jar, err := newSessionJar()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, c := range s.Cookies {
+		if c.expired(now) {
+			continue
+		}
+		cookie := &http.Cookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Path:     c.Path,
+			Expires:  c.Expires,
+			Secure:   c.Secure,
+			HttpOnly: c.HTTPOnly,
+		}
+		if !c.HostOnly {
+			cookie.Domain = c.Domain
+		}
+		jar.jar.SetCookies(&url.URL{Scheme: "https", Host: c.Domain, Path: "/"}, []*http.Cookie{cookie})
+	}
+	s.jar = jar
+	opts.Jar = jar
+
+	return nil
+}
+
+// Update records the headers of opts, and the cookies received since Apply.
+func (s *Session) Update(opts *Options) {
+	s.Headers = nil
+	for name, values := range opts.Header {
+		if slices.Contains(sessionIgnoredHeaders, name) || strings.HasPrefix(name, "If-") {
+			continue
+		}
+		for _, v := range values {
+			s.Headers = append(s.Headers, SessionHeader{Name: name, Value: v})
+		}
+	}
+	slices.SortStableFunc(s.Headers, func(a, b SessionHeader) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	if s.jar == nil {
+		return
+	}
+	now := time.Now()
+	for _, c := range s.jar.received() {
+		s.Cookies = slices.DeleteFunc(s.Cookies, func(old SessionCookie) bool {
+			return old.Name == c.Name && old.Domain == c.Domain && old.Path == c.Path
+		})
+		if c.expired(now) {
+			continue
+		}
+		s.Cookies = append(s.Cookies, c)
+	}
+}
+
+// Save writes the session file.
+func (s *Session) Save() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(s, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(s.path, append(b, '\n'), 0o600)
+}
+
+// sessionJar is a cookie jar remembering the cookies it receives with all
+// their attributes, which http.CookieJar.Cookies does not return.
+type sessionJar struct {
+	jar *cookiejar.Jar
+
+	mu      sync.Mutex
+	cookies []SessionCookie
+}
+
+func newSessionJar() (*sessionJar, error) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sessionJar{jar: jar}, nil
+}
+
+// SetCookies implements http.CookieJar.
+func (j *sessionJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.jar.SetCookies(u, cookies)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	now := time.Now()
+	for _, c := range cookies {
+		sc := SessionCookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Domain:   strings.TrimPrefix(c.Domain, "."),
+			Path:     c.Path,
+			Expires:  c.Expires,
+			Secure:   c.Secure,
+			HTTPOnly: c.HttpOnly,
+		}
+		if sc.Domain == "" {
+			sc.Domain = u.Hostname()
+			sc.HostOnly = true
+		}
+		if sc.Path == "" {
+			sc.Path = "/"
+		}
+		switch {
+		case c.MaxAge < 0:
+			sc.Expires = time.Unix(0, 0)
+		case c.MaxAge > 0:
+			sc.Expires = now.Add(time.Duration(c.MaxAge) * time.Second)
+		}
+		j.cookies = append(j.cookies, sc)
+	}
+}
+
+// Cookies implements http.CookieJar.
+func (j *sessionJar) Cookies(u *url.URL) []*http.Cookie {
+	return j.jar.Cookies(u)
+}
+
+func (j *sessionJar) received() []SessionCookie {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return slices.Clone(j.cookies)
+}
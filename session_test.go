@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionPath(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", "/config")
+	t.Setenv("HOME", "/home")
+
+	path, err := sessionPath("login", "http://localhost:8080/path")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join("/config", "httpcheck", "sessions", "localhost_8080", "login.json"), path)
+
+	path, err = sessionPath("./login.json", "http://localhost:8080/path")
+	require.NoError(t, err)
+	assert.Equal(t, "login.json", path)
+}
+
+func TestTrace_redirectKeepsCookies(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/login":
+			http.SetCookie(rw, &http.Cookie{Name: "sid", Value: "1"})
+			http.Redirect(rw, req, "/home", http.StatusFound)
+		case "/home":
+			if _, err := req.Cookie("sid"); err != nil {
+				rw.WriteHeader(http.StatusUnauthorized)
+			}
+		}
+	}))
+	defer svr.Close()
+
+	opts := NewDefaultOptions()
+	opts.URL = svr.URL + "/login"
+	opts.FollowRedirect = true
+	r, err := Trace(context.Background(), opts)
+
+	require.NoError(t, err)
+	assert.Equal(t, "200", r.Status)
+}
+
+func TestSession(t *testing.T) {
+	var requests int
+	svr := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		requests++
+		switch requests {
+		case 1:
+			http.SetCookie(rw, &http.Cookie{Name: "sid", Value: "1", Path: "/", MaxAge: 3600})
+			http.SetCookie(rw, &http.Cookie{Name: "tmp", Value: "x"})
+		case 2:
+			c, err := req.Cookie("sid")
+			require.NoError(t, err)
+			assert.Equal(t, "1", c.Value)
+			assert.Equal(t, "v", req.Header.Get("X-Custom"))
+			assert.Equal(t, "override", req.Header.Get("X-Other"))
+			user, pass, ok := req.BasicAuth()
+			assert.True(t, ok)
+			assert.Equal(t, "user:pass", user+":"+pass)
+			http.SetCookie(rw, &http.Cookie{Name: "tmp", MaxAge: -1})
+		}
+	}))
+	defer svr.Close()
+	path := filepath.Join(t.TempDir(), "session.json")
+
+	opts := NewDefaultOptions()
+	opts.URL = svr.URL
+	opts.Header.Set("X-Custom", "v")
+	opts.Header.Set("X-Other", "v")
+	s, err := LoadSession(path, opts.URL)
+	require.NoError(t, err)
+	require.NoError(t, s.Apply(opts))
+	_, err = Trace(context.Background(), opts)
+	require.NoError(t, err)
+	s.Update(opts)
+	s.Auth = &SessionAuth{Type: authTypeBasic, Credential: "user:pass"}
+	require.NoError(t, s.Save())
+
+	opts = NewDefaultOptions()
+	opts.URL = svr.URL
+	opts.Header.Set("X-Other", "override")
+	s, err = LoadSession(path, opts.URL)
+	require.NoError(t, err)
+	require.NoError(t, s.Apply(opts))
+	_, err = Trace(context.Background(), opts)
+	require.NoError(t, err)
+	s.Update(opts)
+
+	var names []string
+	for _, c := range s.Cookies {
+		names = append(names, c.Name)
+	}
+	assert.Equal(t, []string{"sid"}, names)
+	assert.Equal(t, []SessionHeader{{Name: "X-Custom", Value: "v"}, {Name: "X-Other", Value: "override"}}, s.Headers)
+	assert.Equal(t, 2, requests, fmt.Sprint(s.Cookies))
+}
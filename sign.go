@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1" // #nosec G505 -- some APIs still sign with HMAC-SHA1.
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Signer signs a request once its headers, query and body are final. body
+// holds the exact bytes that are sent.
+type Signer interface {
+	Sign(req *http.Request, body []byte) error
+}
+
+// sign reads the body of req so that signers see the exact bytes sent, and
+// applies the signers in order.
+func sign(req *http.Request, signers []Signer) error {
+	var body []byte
+	if req.Body != nil {
+		b, err := io.ReadAll(req.Body)
+		close(req.Body)
+		if err != nil {
+			return err
+		}
+		body = b
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+		req.ContentLength = int64(len(body))
+	}
+
+	for _, s := range signers {
+		if err := s.Sign(req, body); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+const defaultHMACCanonical = `{{ .Method }}
+{{ .Path }}
+{{ .Query }}
+{{ .Timestamp }}
+{{ .BodySHA256 }}`
+
+// HMACSigner signs a request with an HMAC of a canonical string rendered
+// from a template.
+type HMACSigner struct {
+	// Key is the secret key.
+	Key string
+	// Algorithm is one of sha1, sha256 or sha512.
+	Algorithm string
+	// Canonical is the template of the signed string, see hmacData for the
+	// available fields. The header function returns a request header.
+	Canonical string
+	// Header is the name of the header carrying the signature.
+	Header string
+	// Format is the template of the header value, where .Signature is the
+	// encoded signature.
+	Format string
+	// Encoding of the signature, hex or base64.
+	Encoding string
+	// TimestampHeader, if set, is added with the current Unix time before
+	// the request is signed.
+	TimestampHeader string
+
+	now func() time.Time
+}
+
+// NewHMACSigner returns an HMAC signer with the default settings.
+func NewHMACSigner() *HMACSigner {
+	return &HMACSigner{
+		Algorithm: "sha256",
+		Canonical: defaultHMACCanonical,
+		Header:    "X-Signature",
+		Format:    "{{ .Signature }}",
+		Encoding:  "hex",
+		now:       time.Now,
+	}
+}
+
+// hmacData is the data available to the canonical string template.
+type hmacData struct {
+	Method     string
+	Host       string
+	Path       string
+	Query      string
+	Timestamp  string
+	Body       string
+	BodySHA256 string
+}
+
+// Sign implements Signer.
+func (s *HMACSigner) Sign(req *http.Request, body []byte) error {
+	var newHash func() hash.Hash
+	switch strings.ToLower(s.Algorithm) {
+	case "sha1":
+		newHash = sha1.New
+	case "sha256":
+		newHash = sha256.New
+	case "sha512":
+		newHash = sha512.New
+	default:
+		return fmt.Errorf("unknown hmac algorithm '%s', must be one of: sha1, sha256, sha512", s.Algorithm)
+	}
+
+	timestamp := strconv.FormatInt(s.now().Unix(), 10)
+	if s.TimestampHeader != "" {
+		req.Header.Set(s.TimestampHeader, timestamp)
+	}
+
+	sum := sha256.Sum256(body)
+	d := hmacData{
+		Method:     req.Method,
+		Host:       requestHost(req),
+		Path:       req.URL.EscapedPath(),
+		Query:      canonicalQuery(req.URL.Query()),
+		Timestamp:  timestamp,
+		Body:       string(body),
+		BodySHA256: hex.EncodeToString(sum[:]),
+	}
+	if d.Path == "" {
+		d.Path = "/"
+	}
+	funcs := template.FuncMap{
+		"header": req.Header.Get,
+	}
+	canonical, err := renderTemplate("hmac canonical string", s.Canonical, funcs, d)
+	if err != nil {
+		return err
+	}
+
+	mac := hmac.New(newHash, []byte(s.Key))
+	mac.Write([]byte(canonical))
+	var signature string
+	switch strings.ToLower(s.Encoding) {
+	case "hex":
+		signature = hex.EncodeToString(mac.Sum(nil))
+	case "base64":
+		signature = base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	default:
+		return fmt.Errorf("unknown hmac encoding '%s', must be one of: hex, base64", s.Encoding)
+	}
+
+	value, err := renderTemplate("hmac header format", s.Format, funcs, struct{ Signature string }{signature})
+	if err != nil {
+		return err
+	}
+	req.Header.Set(s.Header, value)
+
+	return nil
+}
+
+func renderTemplate(name, text string, funcs template.FuncMap, data any) (string, error) {
+	tmpl, err := template.New(name).Funcs(funcs).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s: %w", name, err)
+	}
+
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("invalid %s: %w", name, err)
+	}
+
+	return b.String(), nil
+}
+
+// requestHost returns the Host header value sent with req.
+func requestHost(req *http.Request) string {
+	if req.Host != "" {
+		return req.Host
+	}
+
+	return req.URL.Host
+}
+
+// canonicalQuery encodes q sorted by key then value, with RFC 3986
+// percent-encoding.
+func canonicalQuery(q url.Values) string {
+	var pairs [][2]string
+	for k, values := range q {
+		for _, v := range values {
+			pairs = append(pairs, [2]string{uriEncode(k, true), uriEncode(v, true)})
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i][0] != pairs[j][0] {
+			return pairs[i][0] < pairs[j][0]
+		}
+		return pairs[i][1] < pairs[j][1]
+	})
+
+	encoded := make([]string, len(pairs))
+	for i, p := range pairs {
+		encoded[i] = p[0] + "=" + p[1]
+	}
+
+	return strings.Join(encoded, "&")
+}
+
+// uriEncode percent-encodes every byte of s except the RFC 3986 unreserved
+// characters, and the slash unless encodeSlash is set.
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanonicalQuery(t *testing.T) {
+	q := url.Values{
+		"b":   {"2", "1"},
+		"a-":  {"x"},
+		"a":   {"y z"},
+		"c/d": {"~"},
+	}
+
+	assert.Equal(t, "a=y%20z&a-=x&b=1&b=2&c%2Fd=~", canonicalQuery(q))
+}
+
+func TestHMACSigner_Sign(t *testing.T) {
+	s := NewHMACSigner()
+	s.Key = "secret"
+	s.Canonical = `{{ .Method }} {{ .Path }}?{{ .Query }} {{ header "X-Client" }} {{ .Timestamp }} {{ .Body }}`
+	s.Format = "v1={{ .Signature }}"
+	s.TimestampHeader = "X-Timestamp"
+	s.now = func() time.Time {
+		return time.Unix(1700000000, 0)
+	}
+	req, err := http.NewRequest(http.MethodPost, "https://example.com/items?b=2&a=1", nil)
+	require.NoError(t, err)
+	req.Header.Set("X-Client", "cli")
+
+	require.NoError(t, s.Sign(req, []byte("body")))
+
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte("POST /items?a=1&b=2 cli 1700000000 body"))
+	assert.Equal(t, "v1="+hex.EncodeToString(mac.Sum(nil)), req.Header.Get("X-Signature"))
+	assert.Equal(t, "1700000000", req.Header.Get("X-Timestamp"))
+}
+
+func TestHMACSigner_errors(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "https://example.com", nil)
+	require.NoError(t, err)
+
+	s := NewHMACSigner()
+	s.Algorithm = "md4"
+	require.Error(t, s.Sign(req, nil))
+
+	s = NewHMACSigner()
+	s.Encoding = "base32"
+	require.Error(t, s.Sign(req, nil))
+
+	s = NewHMACSigner()
+	s.Canonical = "{{ .Unknown }}"
+	require.Error(t, s.Sign(req, nil))
+}
+
+func TestTrace_signedBody(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		b, err := io.ReadAll(req.Body)
+		require.NoError(t, err)
+		sum := sha256.Sum256(b)
+		assert.Equal(t, hex.EncodeToString(sum[:]), req.Header.Get("X-Amz-Content-Sha256"))
+		assert.Contains(t, req.Header.Get("Authorization"), "/eu-west-1/s3/aws4_request")
+	}))
+	defer svr.Close()
+
+	s, err := NewSigV4Signer("s3:eu-west-1", AWSCredentials{AccessKeyID: "id", SecretAccessKey: "secret"})
+	require.NoError(t, err)
+	opts := NewDefaultOptions()
+	opts.URL = svr.URL
+	opts.Method = http.MethodPut
+	opts.IsMultipart = true
+	opts.Files = []FormFile{{Field: "f", Path: "testdata/item.txt", ContentType: "text/plain"}}
+	opts.Signers = []Signer{s}
+	_, err = Trace(context.Background(), opts)
+
+	require.NoError(t, err)
+}
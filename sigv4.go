@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	sigv4Algorithm  = "AWS4-HMAC-SHA256"
+	sigv4TimeFormat = "20060102T150405Z"
+)
+
+// sigv4UnsignedHeaders are not part of the signature because proxies or the
+// HTTP client may change them.
+var sigv4UnsignedHeaders = []string{
+	"authorization",
+	"content-length",
+	"expect",
+	"user-agent",
+	"x-amzn-trace-id",
+}
+
+// AWSCredentials are the credentials used by SigV4Signer.
+type AWSCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// LoadAWSCredentials reads the credentials from the standard environment
+// variables, or from the shared credentials file for $AWS_PROFILE.
+func LoadAWSCredentials() (AWSCredentials, error) {
+	c := AWSCredentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if c.AccessKeyID != "" && c.SecretAccessKey != "" {
+		return c, nil
+	}
+
+	profile := os.Getenv("AWS_PROFILE")
+	if profile == "" {
+		profile = "default"
+	}
+	path := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return c, err
+		}
+		path = filepath.Join(home, ".aws", "credentials")
+	}
+
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return c, fmt.Errorf("no AWS credentials in the environment, and cannot read '%s': %w", path, err)
+	}
+	defer close(f)
+
+	section := ""
+	c = AWSCredentials{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		if section != profile {
+			continue
+		}
+		k, v, _ := strings.Cut(line, "=")
+		switch strings.TrimSpace(k) {
+		case "aws_access_key_id":
+			c.AccessKeyID = strings.TrimSpace(v)
+		case "aws_secret_access_key":
+			c.SecretAccessKey = strings.TrimSpace(v)
+		case "aws_session_token":
+			c.SessionToken = strings.TrimSpace(v)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return c, err
+	}
+	if c.AccessKeyID == "" || c.SecretAccessKey == "" {
+		return c, fmt.Errorf("no AWS credentials for profile '%s' in '%s'", profile, path)
+	}
+
+	return c, nil
+}
+
+// SigV4Signer signs requests with AWS Signature Version 4.
+type SigV4Signer struct {
+	Service     string
+	Region      string
+	Credentials AWSCredentials
+
+	now func() time.Time
+}
+
+// NewSigV4Signer returns a signer for "service:region". The region defaults
+// to $AWS_REGION or $AWS_DEFAULT_REGION.
+func NewSigV4Signer(scope string, credentials AWSCredentials) (*SigV4Signer, error) {
+	service, region, _ := strings.Cut(scope, ":")
+	if region == "" {
+		region = os.Getenv("AWS_REGION")
+	}
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if service == "" || region == "" {
+		return nil, fmt.Errorf("'%s' is not a valid sigv4 scope, use service:region", scope)
+	}
+
+	return &SigV4Signer{
+		Service:     service,
+		Region:      region,
+		Credentials: credentials,
+		now:         time.Now,
+	}, nil
+}
+
+// Sign implements Signer.
+func (s *SigV4Signer) Sign(req *http.Request, body []byte) error {
+	t := s.now().UTC()
+	amzDate := t.Format(sigv4TimeFormat)
+	date := t.Format("20060102")
+
+	sum := sha256.Sum256(body)
+	payloadHash := hex.EncodeToString(sum[:])
+	req.Header.Set("X-Amz-Date", amzDate)
+	if s.Credentials.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.Credentials.SessionToken)
+	}
+	if s.Service == "s3" {
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+
+	headers := map[string]string{
+		"host": requestHost(req),
+	}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if len(values) == 0 || slices.Contains(sigv4UnsignedHeaders, name) {
+			continue
+		}
+		trimmed := make([]string, len(values))
+		for i, v := range values {
+			trimmed[i] = strings.Join(strings.Fields(v), " ")
+		}
+		headers[name] = strings.Join(trimmed, ",")
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if s.Service != "s3" {
+		// every service but S3 expects the path to be encoded twice.
+		path = uriEncode(path, false)
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{date, s.Region, s.Service, "aws4_request"}, "/")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		sigv4Algorithm,
+		amzDate,
+		scope,
+		hex.EncodeToString(requestHash[:]),
+	}, "\n")
+
+	key := []byte("AWS4" + s.Credentials.SecretAccessKey)
+	for _, v := range []string{date, s.Region, s.Service, "aws4_request"} {
+		key = hmacSHA256(key, v)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set(authorizationHeader, fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigv4Algorithm, s.Credentials.AccessKeyID, scope, signedHeaders, signature))
+
+	return nil
+}
+
+func hmacSHA256(key []byte, s string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(s))
+
+	return mac.Sum(nil)
+}
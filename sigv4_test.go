@@ -0,0 +1,104 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// the test vectors come from the AWS Signature Version 4 test suite.
+func TestSigV4Signer_Sign(t *testing.T) {
+	cases := []struct {
+		name      string
+		method    string
+		url       string
+		signature string
+	}{
+		{
+			name:      "get-vanilla",
+			method:    http.MethodGet,
+			url:       "https://example.amazonaws.com/",
+			signature: "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		},
+		{
+			name:      "get-vanilla-query-order-key-case",
+			method:    http.MethodGet,
+			url:       "https://example.amazonaws.com/?Param2=value2&Param1=value1",
+			signature: "b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500",
+		},
+		{
+			name:      "post-vanilla",
+			method:    http.MethodPost,
+			url:       "https://example.amazonaws.com/",
+			signature: "5da7c1a2acd57cee7505fc6676e4e544621c30862966e37dddb68e92efbe5d6b",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := NewSigV4Signer("service:us-east-1", AWSCredentials{
+				AccessKeyID:     "AKIDEXAMPLE",
+				SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+			})
+			require.NoError(t, err)
+			s.now = func() time.Time {
+				return time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+			}
+			req, err := http.NewRequest(tc.method, tc.url, nil)
+			require.NoError(t, err)
+
+			require.NoError(t, s.Sign(req, nil))
+			assert.Equal(t, "20150830T123600Z", req.Header.Get("X-Amz-Date"))
+			assert.Equal(t, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature="+tc.signature, req.Header.Get("Authorization"))
+		})
+	}
+}
+
+func TestNewSigV4Signer_region(t *testing.T) {
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+	_, err := NewSigV4Signer("s3", AWSCredentials{})
+	require.Error(t, err)
+
+	t.Setenv("AWS_REGION", "eu-west-1")
+	s, err := NewSigV4Signer("s3", AWSCredentials{})
+	require.NoError(t, err)
+	assert.Equal(t, "eu-west-1", s.Region)
+}
+
+func TestLoadAWSCredentials(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	path := filepath.Join(t.TempDir(), "credentials")
+	content := `[default]
+aws_access_key_id = default-id
+aws_secret_access_key = default-secret
+
+[staging]
+aws_access_key_id = staging-id
+aws_secret_access_key = staging-secret
+aws_session_token = staging-token
+`
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", path)
+	t.Setenv("AWS_PROFILE", "staging")
+
+	c, err := LoadAWSCredentials()
+	require.NoError(t, err)
+	assert.Equal(t, AWSCredentials{AccessKeyID: "staging-id", SecretAccessKey: "staging-secret", SessionToken: "staging-token"}, c)
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "env-id")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "env-secret")
+	c, err = LoadAWSCredentials()
+	require.NoError(t, err)
+	assert.Equal(t, "env-id", c.AccessKeyID)
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_PROFILE", "missing")
+	_, err = LoadAWSCredentials()
+	require.Error(t, err)
+}
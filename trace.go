@@ -3,10 +3,15 @@ package main
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"crypto/tls"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"io"
+	"net"
 	"net/http"
+	"net/http/cookiejar"
 	"net/http/httptrace"
 	"os"
 	"slices"
@@ -34,6 +39,7 @@ type Header struct {
 // Result is the performance metric returned by Trace function.
 type Result struct {
 	URL         string
+	Method      string
 	RemoteAddr  string
 	LocalAddr   string
 	HTTPVersion string
@@ -42,13 +48,82 @@ type Result struct {
 
 	Output string
 
+	// Hops are the auxiliary requests sent before the traced request, such
+	// as a digest authentication challenge.
+	Hops []Hop
+
+	// RequestURL, RequestHeaders and RequestBody are the request as it was
+	// sent. RequestBody is nil when the body was streamed, like a multipart
+	// upload.
+	RequestURL     string
+	RequestHeaders []Header
+	RequestBody    []byte
+
+	// RequestBodySize is the size of the request body, -1 if unknown.
+	RequestBodySize int64
+	// BodySize is the size of the response body.
+	BodySize int64
+
+	// StartedAt is when the request was sent, and EndedAt when its response
+	// was read.
+	StartedAt time.Time
+	EndedAt   time.Time
+	// Timings are the phases of the request that happened, in order.
+	Timings []Timing
+
+	// ResolvedIPs are the addresses of the last DNS lookup.
+	ResolvedIPs []string
+	// CertFingerprint is the SHA-256 fingerprint of the server certificate,
+	// in hex, and CertExpiry its expiry.
+	CertFingerprint string
+	CertExpiry      time.Time
+
+	// UnixSocket is the path of the Unix domain socket the request was
+	// sent over, if any.
+	UnixSocket string
+
 	MetricDNSLookup        int64
 	MetricTCPConnection    int64
+	MetricSocketConnect    int64
 	MetricTLSHandshake     int64
+	MetricRequestUpload    int64
 	MetricServerProcessing int64
 	MetricContentTransfer  int64
 }
 
+// Header returns the first value of the named response header, or "" if
+// there is none.
+func (r *Result) Header(name string) string {
+	name = http.CanonicalHeaderKey(name)
+	for _, h := range r.Headers {
+		if h.Name == name {
+			return h.Value
+		}
+	}
+
+	return ""
+}
+
+// Total returns the sum of all the phases of r.
+func (r *Result) Total() int64 {
+	return r.MetricDNSLookup + r.MetricTCPConnection + r.MetricSocketConnect + r.MetricTLSHandshake +
+		r.MetricRequestUpload + r.MetricServerProcessing + r.MetricContentTransfer
+}
+
+// Timing is when a phase of a request, like its DNS lookup, started and
+// ended.
+type Timing struct {
+	Name  string
+	Start time.Time
+	End   time.Time
+}
+
+// Hop is an auxiliary request sent before the traced request.
+type Hop struct {
+	Name   string
+	Result *Result
+}
+
 func diffMills(t1, t2 time.Time) int64 {
 	return t1.Sub(t2).Milliseconds()
 }
@@ -59,14 +134,49 @@ func close(c io.Closer) {
 	}
 }
 
-// Trace sends a request to the specified URL and returns
-// a performance metirc.
-func Trace(ctx context.Context, opts *Options) (*Result, error) {
-	ctx, cancel := context.WithTimeout(ctx, opts.timeout)
-	defer cancel()
+// newTransport returns the transport used to send the traced request.
+func newTransport(opts *Options) *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	if opts.Insecure {
+		t.TLSClientConfig = &tls.Config{InsecureSkipVerify: true} // #nosec G402 -- requested with --insecure.
+	}
+	if opts.Proxy != nil {
+		t.Proxy = http.ProxyURL(opts.Proxy)
+	}
+	if opts.UnixSocket == "" && opts.Network.IsZero() {
+		return t
+	}
 
+	d := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	dial := d.DialContext
+	if opts.UnixSocket != "" {
+		// the URL still decides the request path and the Host header, but
+		// every connection goes to the socket instead of the URL's host.
+		dial = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return d.DialContext(ctx, "unix", opts.UnixSocket)
+		}
+	}
+	if !opts.Network.IsZero() {
+		d.ControlContext = opts.Network.control
+		dial = opts.Network.dial(dial)
+	}
+	t.DialContext = dial
+
+	return t
+}
+
+// newRequest builds the request described by opts.
+func newRequest(ctx context.Context, opts *Options) (*http.Request, error) {
 	var body io.Reader
-	if len(opts.Data) > 0 {
+	var multipartBody *multipartBody
+	if opts.RawBody != nil {
+		body = bytes.NewReader(opts.RawBody)
+	} else if opts.isMultipart() {
+		multipartBody = newMultipartBody(opts.FormData, opts.Files)
+	} else if len(opts.Data) > 0 {
 		b, err := json.Marshal(opts.Data)
 		if err != nil {
 			return nil, err
@@ -86,6 +196,22 @@ func Trace(ctx context.Context, opts *Options) (*Result, error) {
 		req.Header.Set(contentTypeHeader, contentTypeForm)
 		req.Header.Del(acceptHeader)
 	}
+	if multipartBody != nil {
+		n, err := multipartBody.ContentLength()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = multipartBody.Reader()
+		req.ContentLength = n
+		req.Header.Set(contentTypeHeader, multipartBody.ContentType())
+		req.Header.Del(acceptHeader)
+	}
+	if opts.ContentType != "" {
+		req.Header.Set(contentTypeHeader, opts.ContentType)
+	}
+	if auth := authorization(opts); auth != "" {
+		req.Header.Set(authorizationHeader, auth)
+	}
 	for k, values := range opts.Header {
 		req.Header.Del(k)
 		for _, v := range values {
@@ -100,16 +226,157 @@ func Trace(ctx context.Context, opts *Options) (*Result, error) {
 	}
 	req.URL.RawQuery = q.Encode()
 
+	if len(opts.Signers) > 0 {
+		if err := sign(req, opts.Signers); err != nil {
+			return nil, err
+		}
+	}
+
+	return req, nil
+}
+
+// Trace sends a request to the specified URL and returns
+// a performance metirc.
+func Trace(ctx context.Context, opts *Options) (*Result, error) {
+	if opts.OTel == nil && opts.Push == nil {
+		return trace(ctx, opts)
+	}
+
+	traced := opts
+	var sc SpanContext
+	var parentID [8]byte
+	if opts.OTel != nil {
+		// the request carries the client span, so that the spans of the
+		// server are its children.
+		sc, parentID = NewSpanContext(opts.Header.Get(traceparentHeader))
+		traced = opts.withHeader(traceparentHeader, sc.Traceparent())
+	}
+	start := time.Now()
+	r, err := trace(ctx, traced)
+
+	var exportErrs []error
+	if opts.OTel != nil {
+		exportErrs = append(exportErrs, opts.OTel.Export(ctx, traceSpans(sc, parentID, opts.Method, opts.URL, start, r, err)))
+	}
+	if opts.Push != nil {
+		exportErrs = append(exportErrs, opts.Push.Push(ctx, opts.URL, r, err))
+	}
+	// the request itself was traced, so a failed export does not lose it.
+	if exportErr := errors.Join(exportErrs...); exportErr != nil {
+		opts.warn(exportErr)
+	}
+
+	return r, err
+}
+
+func trace(ctx context.Context, opts *Options) (*Result, error) {
+	ctx, cancel := context.WithTimeout(ctx, opts.timeout)
+	defer cancel()
+
+	cli := newClient(opts)
+	// the transport is used by this trace only, so its connections would
+	// otherwise stay open until the idle timeout.
+	defer cli.CloseIdleConnections()
+	var hops []Hop
+	if opts.OAuth2 != nil {
+		token, hop, err := opts.OAuth2.token(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		if hop != nil {
+			hops = append(hops, Hop{Name: "oauth2 token", Result: hop})
+		}
+		opts = opts.withHeader(authorizationHeader, "Bearer "+token)
+	}
+	if opts.AuthType == authTypeDigest {
+		hop, authorization, err := digestChallenge(ctx, cli, opts)
+		if err != nil {
+			return nil, err
+		}
+		if authorization == "" {
+			// the server did not ask for credentials, so the challenge
+			// request is the traced request.
+			recordHAR(opts, append(hopResults(hops), hop))
+			return hop, nil
+		}
+		hops = append(hops, Hop{Name: "digest challenge", Result: hop})
+		opts = opts.withHeader(authorizationHeader, authorization)
+	}
+
+	req, err := newRequest(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	r, err := traceRequest(cli, req, opts)
+	if err != nil {
+		return nil, err
+	}
+	r.Hops = hops
+	recordHAR(opts, append(hopResults(hops), r))
+
+	return r, nil
+}
+
+// recordHAR adds the results to the HAR file of opts, if any. Like a failed
+// export, a failed write is a warning, so that the traced request is kept.
+func recordHAR(opts *Options, results []*Result) {
+	if opts.HAR == nil {
+		return
+	}
+	if err := opts.HAR.Record(results...); err != nil {
+		opts.warn(err)
+	}
+}
+
+// hopResults returns the results of hops.
+func hopResults(hops []Hop) []*Result {
+	results := make([]*Result, len(hops))
+	for i, h := range hops {
+		results[i] = h.Result
+	}
+
+	return results
+}
+
+// newClient returns the client used to send the traced requests. Unless
+// opts has a cookie jar, cookies are kept for the requests sent by the
+// client only, such as the ones of a redirect chain.
+func newClient(opts *Options) *http.Client {
+	jar := opts.Jar
+	if jar == nil {
+		// cookiejar.New never fails without options.
+		jar, _ = cookiejar.New(nil)
+	}
+	cli := &http.Client{
+		Jar:       jar,
+		Transport: newTransport(opts),
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	if opts.FollowRedirect {
+		cli.CheckRedirect = nil
+	}
+
+	return cli
+}
+
+// traceRequest sends req and returns its performance metric.
+func traceRequest(cli *http.Client, req *http.Request, opts *Options) (*Result, error) {
 	r := &Result{
 		URL: opts.URL,
 	}
-	var t0, t1, t2, t3, t4, t5, t6, t7, t8 time.Time
+	var t0, t1, t2, t3, t4, t5, t6, t7, t8, tw time.Time
 	trace := &httptrace.ClientTrace{
 		DNSStart: func(di httptrace.DNSStartInfo) {
 			t0 = time.Now()
 		},
 		DNSDone: func(di httptrace.DNSDoneInfo) {
 			t1 = time.Now()
+			r.ResolvedIPs = nil
+			for _, a := range di.Addrs {
+				r.ResolvedIPs = append(r.ResolvedIPs, a.IP.String())
+			}
 		},
 		ConnectStart: func(network, addr string) {
 			t2 = time.Now()
@@ -133,25 +400,27 @@ func Trace(ctx context.Context, opts *Options) (*Result, error) {
 				return
 			}
 			t5 = time.Now()
+			if len(cs.PeerCertificates) > 0 {
+				cert := cs.PeerCertificates[0]
+				sum := sha256.Sum256(cert.Raw)
+				r.CertFingerprint = hex.EncodeToString(sum[:])
+				r.CertExpiry = cert.NotAfter
+			}
 		},
 		GotConn: func(gci httptrace.GotConnInfo) {
 			t6 = time.Now()
 			r.LocalAddr = gci.Conn.LocalAddr().String()
 		},
+		WroteRequest: func(wri httptrace.WroteRequestInfo) {
+			tw = time.Now()
+		},
 		GotFirstResponseByte: func() {
 			t7 = time.Now()
 		},
 	}
 	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
 
-	cli := http.Client{
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			return http.ErrUseLastResponse
-		},
-	}
-	if opts.FollowRedirect {
-		cli.CheckRedirect = nil
-	}
+	r.StartedAt = time.Now()
 	resp, err := cli.Do(req)
 	if err != nil {
 		return nil, err
@@ -163,27 +432,85 @@ func Trace(ctx context.Context, opts *Options) (*Result, error) {
 		return nil, err
 	}
 	defer close(f)
-	if _, err := io.Copy(f, resp.Body); err != nil {
+	if r.BodySize, err = io.Copy(f, resp.Body); err != nil {
 		return nil, err
 	}
 	t8 = time.Now()
 
-	r.MetricDNSLookup = diffMills(t1, t0)
-	r.MetricTCPConnection = diffMills(t3, t2)
+	if opts.UnixSocket != "" {
+		r.UnixSocket = opts.UnixSocket
+		r.MetricSocketConnect = diffMills(t3, t2)
+	} else {
+		r.MetricDNSLookup = diffMills(t1, t0)
+		r.MetricTCPConnection = diffMills(t3, t2)
+	}
 	r.MetricTLSHandshake = diffMills(t5, t4)
 	r.MetricServerProcessing = diffMills(t7, t6)
+	if req.ContentLength != 0 {
+		// with a body, the time spent sending it is reported on its own.
+		r.RequestBodySize = req.ContentLength
+		r.MetricRequestUpload = diffMills(tw, t6)
+		r.MetricServerProcessing = diffMills(t7, tw)
+	}
 	r.MetricContentTransfer = diffMills(t8, t7)
+	r.EndedAt = t8
+	r.Timings = happened(
+		Timing{"dns", t0, t1},
+		Timing{"connect", t2, t3},
+		Timing{"tls", t4, t5},
+		Timing{"request write", t6, tw},
+		Timing{"server processing", tw, t7},
+		Timing{"content transfer", t7, t8},
+	)
 
 	r.HTTPVersion = resp.Proto
-	for name, values := range resp.Header {
+	r.Headers = sortedHeaders(resp.Header)
+	// the request of the response, the last one of a redirect chain.
+	sent := resp.Request
+	r.Method = sent.Method
+	r.RequestURL = sent.URL.String()
+	r.RequestHeaders = sortedHeaders(sent.Header)
+	if sent.ContentLength != 0 && sent.GetBody != nil {
+		body, err := sent.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		if r.RequestBody, err = io.ReadAll(body); err != nil {
+			return nil, err
+		}
+	}
+	r.Status = strconv.Itoa(resp.StatusCode)
+	r.Output = f.Name()
+
+	return r, nil
+}
+
+// happened returns the timings of the phases that happened, the ones with
+// both a start and an end. A reused connection has no DNS lookup, connect
+// or TLS handshake.
+func happened(timings ...Timing) []Timing {
+	var happened []Timing
+	for _, t := range timings {
+		if !t.Start.IsZero() && !t.End.IsZero() {
+			happened = append(happened, t)
+		}
+	}
+
+	return happened
+}
+
+// sortedHeaders returns the headers of h sorted by name and value.
+func sortedHeaders(h http.Header) []Header {
+	var headers []Header
+	for name, values := range h {
 		for _, value := range values {
-			r.Headers = append(r.Headers, Header{
+			headers = append(headers, Header{
 				Name:  name,
 				Value: value,
 			})
 		}
 	}
-	slices.SortFunc(r.Headers, func(a, b Header) int {
+	slices.SortFunc(headers, func(a, b Header) int {
 		if a.Name > b.Name {
 			return 1
 		}
@@ -198,8 +525,6 @@ func Trace(ctx context.Context, opts *Options) (*Result, error) {
 		}
 		return 0
 	})
-	r.Status = strconv.Itoa(resp.StatusCode)
-	r.Output = f.Name()
 
-	return r, nil
+	return headers
 }
@@ -2,11 +2,18 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -139,3 +146,161 @@ func TestTrace_override_default_header(t *testing.T) {
 
 	require.NoError(t, err)
 }
+
+func TestTrace_unixSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "httpcheck.sock")
+	l, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	svr := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "example.com", req.Host)
+		assert.Equal(t, "/info", req.URL.Path)
+		fmt.Fprint(rw, "data")
+	}))
+	svr.Listener = l
+	svr.Start()
+	defer svr.Close()
+
+	opts := NewDefaultOptions()
+	opts.UnixSocket = socket
+	opts.URL = "http://example.com/info"
+	r, err := Trace(context.Background(), opts)
+
+	require.NoError(t, err)
+	assert.Equal(t, "200", r.Status)
+	assert.Equal(t, socket, r.UnixSocket)
+	assert.Equal(t, socket, r.RemoteAddr)
+	assert.Zero(t, r.MetricDNSLookup)
+	assert.Zero(t, r.MetricTCPConnection)
+}
+
+func TestTrace_removeDefaultHeader(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		assert.NotContains(t, req.Header, "Accept")
+		assert.Equal(t, []string{""}, req.Header.Values("X-Empty"))
+	}))
+	defer svr.Close()
+
+	opts := NewDefaultOptions()
+	opts.URL = svr.URL
+	opts.Header["Accept"] = nil
+	opts.Header.Add("X-Empty", "")
+	_, err := Trace(context.Background(), opts)
+
+	require.NoError(t, err)
+}
+
+func TestTrace_multipart(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		assert.Positive(t, req.ContentLength)
+		require.NoError(t, req.ParseMultipartForm(1024))
+		assert.Equal(t, "john", req.FormValue("name"))
+
+		f, h, err := req.FormFile("doc")
+		require.NoError(t, err)
+		defer close(f)
+		assert.Equal(t, "item.txt", h.Filename)
+		assert.Equal(t, "text/plain", h.Header.Get("Content-Type"))
+		b, err := io.ReadAll(f)
+		require.NoError(t, err)
+		assert.Equal(t, "file value\n", string(b))
+	}))
+	defer svr.Close()
+
+	opts := NewDefaultOptions()
+	opts.URL = svr.URL
+	opts.Method = http.MethodPost
+	opts.IsMultipart = true
+	opts.FormData.Set("name", "john")
+	opts.Files = []FormFile{{Field: "doc", Path: "testdata/item.txt", ContentType: "text/plain"}}
+	r, err := Trace(context.Background(), opts)
+
+	require.NoError(t, err)
+	assert.Positive(t, r.RequestBodySize)
+}
+
+func TestTrace_rawBody(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "application/xml", req.Header.Get("Content-Type"))
+		b, err := io.ReadAll(req.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "<a>1</a>", string(b))
+	}))
+	defer svr.Close()
+
+	opts := NewDefaultOptions()
+	opts.URL = svr.URL
+	opts.Method = http.MethodPost
+	opts.RawBody = []byte("<a>1</a>")
+	opts.ContentType = "application/xml"
+	r, err := Trace(context.Background(), opts)
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(8), r.RequestBodySize)
+}
+
+func TestTrace_closesIdleConnections(t *testing.T) {
+	var mu sync.Mutex
+	open := 0
+	svr := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	svr.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch state {
+		case http.StateNew:
+			open++
+		case http.StateClosed, http.StateHijacked:
+			open--
+		}
+	}
+	svr.Start()
+	defer svr.Close()
+
+	opts := NewDefaultOptions()
+	opts.URL = svr.URL
+	for range 5 {
+		_, err := Trace(context.Background(), opts)
+		require.NoError(t, err)
+	}
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return open == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestTrace_insecure(t *testing.T) {
+	svr := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	defer svr.Close()
+
+	opts := NewDefaultOptions()
+	opts.URL = svr.URL
+	_, err := Trace(context.Background(), opts)
+	require.Error(t, err)
+
+	opts.Insecure = true
+	r, err := Trace(context.Background(), opts)
+	require.NoError(t, err)
+	assert.Equal(t, "200", r.Status)
+	sum := sha256.Sum256(svr.Certificate().Raw)
+	assert.Equal(t, hex.EncodeToString(sum[:]), r.CertFingerprint)
+	assert.Equal(t, svr.Certificate().NotAfter, r.CertExpiry)
+}
+
+func TestTrace_proxy(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "http://example.invalid/path", req.URL.String())
+		rw.WriteHeader(http.StatusTeapot)
+	}))
+	defer proxy.Close()
+
+	opts := NewDefaultOptions()
+	opts.URL = "http://example.invalid/path"
+	u, err := url.Parse(proxy.URL)
+	require.NoError(t, err)
+	opts.Proxy = u
+	r, err := Trace(context.Background(), opts)
+
+	require.NoError(t, err)
+	assert.Equal(t, "418", r.Status)
+}
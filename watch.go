@@ -0,0 +1,337 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// watchHistory is the number of runs kept by the watch mode.
+	watchHistory = 60
+	// watchAnomalies is the number of anomalies listed by the watch mode.
+	watchAnomalies = 5
+	// watchChanges is the number of changed runs listed by the watch mode.
+	watchChanges = 5
+	// anomalyFactor is how many times the median total time a run takes to
+	// be an anomaly, once there are anomalyMinRuns runs.
+	anomalyFactor  = 3
+	anomalyMinRuns = 5
+
+	ansiAltScreen  = "\x1b[?1049h\x1b[?25l"
+	ansiMainScreen = "\x1b[?25h\x1b[?1049l"
+	ansiClear      = "\x1b[H\x1b[2J"
+)
+
+// sparks are the levels of a sparkline, lowest first.
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// Watch is the state of the watch mode: the latest runs of a request, the
+// count of each status, the anomalies and the changed runs.
+type Watch struct {
+	Runs      int
+	Samples   []WatchSample
+	Statuses  map[string]int
+	Anomalies []WatchSample
+	Changes   []WatchSample
+}
+
+// WatchSample is a run of the watch mode.
+type WatchSample struct {
+	At     time.Time
+	Result *Result
+	Err    error
+	// Anomaly describes why the run is an anomaly, if it is one.
+	Anomaly string
+	// Changes are the changes since the previous run.
+	Changes []Change
+}
+
+// WatchConfig configures the watch mode.
+type WatchConfig struct {
+	Interval time.Duration
+	// Detector detects the changes between runs, which are sent to the
+	// notifiers. Changes are not detected if it is nil.
+	Detector  *ChangeDetector
+	Notifiers []Notifier
+}
+
+// NewWatch returns an empty watch.
+func NewWatch() *Watch {
+	return &Watch{Statuses: map[string]int{}}
+}
+
+// Add records a run. A run is an anomaly if it failed, got a server error,
+// or took more than anomalyFactor times the median total time.
+func (w *Watch) Add(at time.Time, r *Result, err error) {
+	s := WatchSample{At: at, Result: r, Err: err}
+	switch {
+	case err != nil:
+		w.Statuses["error"]++
+		s.Anomaly = err.Error()
+	case strings.HasPrefix(r.Status, "5"):
+		w.Statuses[r.Status]++
+		s.Anomaly = "status " + r.Status
+	default:
+		w.Statuses[r.Status]++
+		var totals []int64
+		for _, prev := range w.Samples {
+			if prev.Result != nil {
+				totals = append(totals, prev.Result.Total())
+			}
+		}
+		if len(totals) >= anomalyMinRuns {
+			median := statistic("p50", totals)
+			if total := r.Total(); total > anomalyFactor*max(median, 1) {
+				s.Anomaly = fmt.Sprintf("total %s, %.1f× the median %s", fmtms(total), float64(total)/float64(max(median, 1)), fmtms(median))
+			}
+		}
+	}
+
+	w.Runs++
+	if latest := w.latest(); latest != nil && latest.Result != nil && r != nil {
+		// only the body of the latest result is printed.
+		_ = os.Remove(latest.Result.Output)
+	}
+	w.Samples = append(w.Samples, s)
+	if len(w.Samples) > watchHistory {
+		w.Samples = w.Samples[1:]
+	}
+	if s.Anomaly != "" {
+		w.addAnomaly(s)
+	}
+}
+
+func (w *Watch) addAnomaly(s WatchSample) {
+	w.Anomalies = append(w.Anomalies, s)
+	if len(w.Anomalies) > watchAnomalies {
+		w.Anomalies = w.Anomalies[1:]
+	}
+}
+
+// Changed records the changes of the latest run.
+func (w *Watch) Changed(changes []Change) {
+	if len(changes) == 0 || len(w.Samples) == 0 {
+		return
+	}
+	s := &w.Samples[len(w.Samples)-1]
+	s.Changes = changes
+	w.Changes = append(w.Changes, *s)
+	if len(w.Changes) > watchChanges {
+		w.Changes = w.Changes[1:]
+	}
+}
+
+// NotifyFailed records a failed notification as an anomaly.
+func (w *Watch) NotifyFailed(at time.Time, err error) {
+	w.addAnomaly(WatchSample{At: at, Err: err, Anomaly: err.Error()})
+}
+
+// latest returns the latest run with a result, or nil.
+func (w *Watch) latest() *WatchSample {
+	for i := len(w.Samples) - 1; i >= 0; i-- {
+		if w.Samples[i].Result != nil {
+			return &w.Samples[i]
+		}
+	}
+
+	return nil
+}
+
+// Render writes a frame of the dashboard: the latest result, a table with
+// the history of each phase, the status counts, the latest anomalies and
+// the latest changes.
+func (w *Watch) Render(title string, opts ...PrintOption) error {
+	options := &printOptions{
+		out:   os.Stdout,
+		color: true,
+	}
+	for _, o := range opts {
+		o(options)
+	}
+	green, gray, red, cyan := green, gray, red, cyan
+	if !options.color {
+		green, gray, red, cyan = noColor, noColor, noColor, noColor
+	}
+	out := options.out
+
+	fmt.Fprintf(out, "%s %s\n", cyan(title), gray(fmt.Sprintf("(run %d)", w.Runs)))
+	if len(w.Samples) > 0 {
+		if last := w.Samples[len(w.Samples)-1]; last.Err != nil {
+			fmt.Fprintf(out, "\n%s %s %s\n", gray(last.At.Format(time.TimeOnly)), red("error:"), last.Err)
+		}
+	}
+	if latest := w.latest(); latest != nil {
+		fmt.Fprintf(out, "\n%s\n", gray("latest result at "+latest.At.Format(time.TimeOnly)))
+		if err := PrintResult(latest.Result, append(opts, WithOut(out))...); err != nil {
+			return err
+		}
+	}
+
+	rows := [][]string{{"PHASE", "LAST", "MIN", "AVG", "MAX"}}
+	var sparklines []string
+	for _, c := range reportColumns {
+		var values []int64
+		var anomalies []bool
+		for _, s := range w.Samples {
+			if s.Result != nil {
+				values = append(values, c.metric(s.Result))
+				anomalies = append(anomalies, s.Anomaly != "")
+			}
+		}
+		if len(values) == 0 {
+			continue
+		}
+		rows = append(rows, []string{
+			c.name,
+			fmtms(values[len(values)-1]),
+			fmtms(statistic("min", values)),
+			fmtms(statistic("avg", values)),
+			fmtms(statistic("max", values)),
+		})
+		var b strings.Builder
+		for i, spark := range sparkline(values) {
+			if anomalies[i] {
+				b.WriteString(red(string(spark)))
+				continue
+			}
+			b.WriteRune(spark)
+		}
+		sparklines = append(sparklines, b.String())
+	}
+	if len(rows) > 1 {
+		fmt.Fprintln(out)
+		for i, line := range formatTable(rows) {
+			if i == 0 {
+				fmt.Fprintln(out, green(line+"  HISTORY"))
+				continue
+			}
+			fmt.Fprintf(out, "%s  %s\n", line, sparklines[i-1])
+		}
+	}
+
+	statuses := make([]string, 0, len(w.Statuses))
+	for status := range w.Statuses {
+		statuses = append(statuses, status)
+	}
+	slices.Sort(statuses)
+	counts := make([]string, len(statuses))
+	for i, status := range statuses {
+		count := fmt.Sprintf("%s ×%d", status, w.Statuses[status])
+		if status == "error" || strings.HasPrefix(status, "5") {
+			count = red(count)
+		}
+		counts[i] = count
+	}
+	fmt.Fprintf(out, "\n%s  %s\n", green("STATUS"), strings.Join(counts, "  "))
+
+	if len(w.Anomalies) > 0 {
+		fmt.Fprintf(out, "\n%s\n", green("ANOMALIES"))
+		for i := len(w.Anomalies) - 1; i >= 0; i-- {
+			a := w.Anomalies[i]
+			fmt.Fprintf(out, "%s  %s\n", gray(a.At.Format(time.TimeOnly)), red(a.Anomaly))
+		}
+	}
+
+	if len(w.Changes) > 0 {
+		fmt.Fprintf(out, "\n%s\n", green("CHANGES"))
+		for i := len(w.Changes) - 1; i >= 0; i-- {
+			c := w.Changes[i]
+			for _, change := range c.Changes {
+				fmt.Fprintf(out, "%s  %s\n", gray(c.At.Format(time.TimeOnly)), cyan(change.String()))
+			}
+		}
+	}
+
+	return nil
+}
+
+// sparkline returns a spark for each value, scaled between the minimum and
+// the maximum values.
+func sparkline(values []int64) []rune {
+	lo, hi := slices.Min(values), slices.Max(values)
+	sparkline := make([]rune, len(values))
+	for i, v := range values {
+		level := 0
+		if hi > lo {
+			level = int((v - lo) * int64(len(sparks)-1) / (hi - lo))
+		}
+		sparkline[i] = sparks[level]
+	}
+
+	return sparkline
+}
+
+// RunWatch traces the request of opts at every interval of config, and
+// redraws the dashboard after each run until ctx is done. On a terminal,
+// the dashboard is drawn on the alternate screen, which is restored on
+// return. The changes of a run are sent to each notifier of config.
+func RunWatch(ctx context.Context, opts *Options, config WatchConfig, out io.Writer, printOpts ...PrintOption) error {
+	terminal := isTerminal(out)
+	if terminal {
+		fmt.Fprint(out, ansiAltScreen)
+		defer fmt.Fprint(out, ansiMainScreen)
+	} else {
+		printOpts = append(printOpts, WithNoColor())
+	}
+
+	title := fmt.Sprintf("%s %s every %s", opts.Method, opts.URL, config.Interval)
+	w := NewWatch()
+	ticker := time.NewTicker(config.Interval)
+	defer ticker.Stop()
+	for {
+		at := time.Now()
+		r, err := Trace(ctx, opts)
+		if ctx.Err() != nil {
+			return nil
+		}
+		// the changes are detected before the body of the previous result
+		// is removed.
+		var changes []Change
+		if config.Detector != nil {
+			var detectErr error
+			if changes, detectErr = config.Detector.Detect(r, err); detectErr != nil {
+				// like a body file missing for a moment, so the watch goes on.
+				opts.warn(detectErr)
+			}
+		}
+		w.Add(at, r, err)
+		if len(changes) > 0 {
+			w.Changed(changes)
+			n := Notification{URL: opts.URL, Time: at, Status: "error", Changes: changes}
+			if r != nil {
+				n.Status = r.Status
+			}
+			for _, notifier := range config.Notifiers {
+				if err := notifier.Notify(ctx, n); err != nil {
+					w.NotifyFailed(time.Now(), err)
+				}
+			}
+		}
+
+		// the frame is drawn at once, to avoid flickering.
+		var frame bytes.Buffer
+		if terminal {
+			frame.WriteString(ansiClear)
+		} else if w.Runs > 1 {
+			frame.WriteString("\n")
+		}
+		if err := w.Render(title, append(printOpts, WithOut(&frame))...); err != nil {
+			return err
+		}
+		if _, err := out.Write(frame.Bytes()); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
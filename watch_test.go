@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatch_Add(t *testing.T) {
+	w := NewWatch()
+	at := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < anomalyMinRuns; i++ {
+		w.Add(at, &Result{Status: "200", MetricServerProcessing: 100}, nil)
+	}
+	assert.Empty(t, w.Anomalies)
+
+	w.Add(at, &Result{Status: "200", MetricServerProcessing: 301}, nil)
+	w.Add(at, &Result{Status: "503", MetricServerProcessing: 100}, nil)
+	w.Add(at, nil, errors.New("connection refused"))
+
+	assert.Equal(t, 8, w.Runs)
+	assert.Equal(t, map[string]int{"200": 6, "503": 1, "error": 1}, w.Statuses)
+	require.Len(t, w.Anomalies, 3)
+	assert.Equal(t, "total 301ms, 3.0× the median 100ms", w.Anomalies[0].Anomaly)
+	assert.Equal(t, "status 503", w.Anomalies[1].Anomaly)
+	assert.Equal(t, "connection refused", w.Anomalies[2].Anomaly)
+}
+
+func TestWatch_Add_history(t *testing.T) {
+	w := NewWatch()
+	dir := t.TempDir()
+	var outputs []string
+	for i := 0; i < watchHistory+2; i++ {
+		output := filepath.Join(dir, time.Duration(i).String())
+		require.NoError(t, os.WriteFile(output, nil, 0o600))
+		outputs = append(outputs, output)
+		w.Add(time.Now(), &Result{Status: "200", Output: output}, nil)
+	}
+
+	assert.Len(t, w.Samples, watchHistory)
+	assert.NoFileExists(t, outputs[0])
+	assert.FileExists(t, outputs[len(outputs)-1])
+}
+
+func TestWatch_Changed(t *testing.T) {
+	w := NewWatch()
+	w.Changed([]Change{{What: "status"}})
+	assert.Empty(t, w.Changes)
+
+	at := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < watchChanges+1; i++ {
+		w.Add(at, &Result{Status: "200"}, nil)
+		w.Changed([]Change{{What: "body", From: "a", To: "b"}})
+	}
+	w.Add(at, &Result{Status: "200"}, nil)
+	w.Changed(nil)
+
+	assert.Len(t, w.Changes, watchChanges)
+	assert.Equal(t, []Change{{What: "body", From: "a", To: "b"}}, w.Samples[0].Changes)
+	assert.Nil(t, w.Samples[len(w.Samples)-1].Changes)
+}
+
+func TestSparkline(t *testing.T) {
+	assert.Equal(t, "▁▂▄█▁", string(sparkline([]int64{10, 20, 40, 80, 10})))
+	assert.Equal(t, "▁▁", string(sparkline([]int64{5, 5})))
+}
+
+func TestWatch_Render(t *testing.T) {
+	body, err := os.ReadFile("testdata/response_body.txt")
+	require.NoError(t, err)
+	w := NewWatch()
+	at := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	for i, server := range []int64{80, 95, 70, 85, 90, 400} {
+		// the watch removes the body of the previous results, so only the
+		// latest one can be in testdata.
+		output := "testdata/response_body.txt"
+		if i < 5 {
+			output = filepath.Join(t.TempDir(), "body")
+			require.NoError(t, os.WriteFile(output, body, 0o600))
+		}
+		w.Add(at.Add(time.Duration(i)*2*time.Second), &Result{
+			URL:                    "http://example.com",
+			RemoteAddr:             "1.1.1.1:80",
+			LocalAddr:              "192.168.1.1:63917",
+			HTTPVersion:            "HTTP/1.1",
+			Status:                 "200",
+			Output:                 output,
+			MetricDNSLookup:        int64(i % 2),
+			MetricTCPConnection:    10,
+			MetricServerProcessing: server,
+			MetricContentTransfer:  1,
+		}, nil)
+	}
+	w.Changed([]Change{{What: "total time", From: "101ms", To: "412ms"}})
+	w.NotifyFailed(at.Add(10*time.Second), errors.New("notify webhook failed with status 502"))
+	w.Add(at.Add(12*time.Second), nil, errors.New("context deadline exceeded"))
+	w.Changed([]Change{{What: "status", From: "200", To: "error"}})
+	var out bytes.Buffer
+
+	err = w.Render("GET http://example.com every 2s", WithOut(&out), WithNoColor())
+
+	require.NoError(t, err)
+	goldenAssert(t, "watch.golden", out.String())
+}